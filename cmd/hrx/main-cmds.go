@@ -15,18 +15,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 
 	"github.com/urfave/cli/v2"
 
 	hrxutil "github.com/go-coreutils/hrx"
 
+	"github.com/go-corelibs/hrx"
 	"github.com/go-corelibs/notify"
 	clPath "github.com/go-corelibs/path"
 )
 
+// gStdioName is the --archive value for using stdin or stdout
+const gStdioName = "-"
+
+// gBoundaryAuto is the --boundary value for choosing the boundary size from
+// the contents archived
+const gBoundaryAuto = "auto"
+
 type opMode uint8
 
 const (
@@ -34,50 +45,118 @@ const (
 	opCreate opMode = iota + 1
 	opExtract
 	opList
+	opAppend
+	opUpdate
+	opDelete
+	opDiff
+	opVerify
+	opCat
 )
 
-func orCheck(argv ...bool) (ok bool) {
-	for _, arg := range argv {
-		if ok = arg; ok {
-			return
-		}
+var gOpModeFlags = []struct {
+	op   opMode
+	flag *cli.BoolFlag
+}{
+	{op: opCreate, flag: gCreateFlag},
+	{op: opExtract, flag: gExtractFlag},
+	{op: opList, flag: gListFlag},
+	{op: opAppend, flag: gAppendFlag},
+	{op: opUpdate, flag: gUpdateFlag},
+	{op: opDelete, flag: gDeleteFlag},
+	{op: opDiff, flag: gDiffFlag},
+	{op: opVerify, flag: gVerifyFlag},
+	{op: opCat, flag: gCatFlag},
+}
+
+var gOverwriteFlags = []struct {
+	policy string
+	flag   *cli.BoolFlag
+}{
+	{policy: hrxutil.OverwriteKeep, flag: gKeepOldFilesFlag},
+	{policy: hrxutil.OverwriteSkipNewer, flag: gSkipNewerFlag},
+	{policy: hrxutil.OverwriteBackup, flag: gBackupFlag},
+}
+
+func prepareOptions(ctx *cli.Context) (opt *hrxutil.Options) {
+	// the --boundary value is validated by action
+	boundary, _ := prepareBoundary(ctx)
+	return &hrxutil.Options{
+		All:           ctx.Bool(gAllFlag.Name),
+		Recurse:       ctx.Bool(gRecurseFlag.Name),
+		Boundary:      boundary,
+		PruneDir:      ctx.Bool(gPruneDirFlag.Name),
+		KeepEmpty:     ctx.Bool(gKeepEmptyFlag.Name),
+		TrimPrefix:    ctx.String(gTrimPrefixFlag.Name),
+		Unified:       ctx.Bool(gUnifiedFlag.Name),
+		Include:       ctx.StringSlice(gIncludeFlag.Name),
+		Exclude:       ctx.StringSlice(gExcludeFlag.Name),
+		NoIgnore:      ctx.Bool(gNoIgnoreFlag.Name),
+		GitIgnore:     ctx.Bool(gGitIgnoreFlag.Name),
+		Binary:        ctx.String(gBinaryFlag.Name),
+		MaxSize:       ctx.Int64(gMaxSizeFlag.Name),
+		Strict:        ctx.Bool(gStrictFlag.Name),
+		UnsafePaths:   ctx.Bool(gUnsafePathsFlag.Name),
+		DryRun:        ctx.Bool(gDryRunFlag.Name),
+		Format:        ctx.String(gFormatFlag.Name),
+		Workers:       ctx.Int(gWorkersFlag.Name),
+		Sort:          ctx.String(gSortFlag.Name),
+		Comment:       ctx.String(gCommentFlag.Name),
+		CommentFile:   ctx.String(gCommentFileFlag.Name),
+		EntryComments: prepareEntryComments(ctx),
+		Notifier:      prepareNotifier(ctx),
+	}
+}
+
+func prepareNotifier(ctx *cli.Context) (notifier notify.Notifier) {
+	if ctx.Bool(gVerboseFlag.Name) || ctx.Bool(gListFlag.Name) || ctx.Bool(gDiffFlag.Name) || ctx.Bool(gDryRunFlag.Name) {
+		return notify.New(notify.Info).Make()
+	}
+	return notify.New(notify.Error).Make()
+}
+
+func prepareEntryComments(ctx *cli.Context) (values []string) {
+	if e, ok := ctx.Generic(gEntryCommentFlag.Name).(*entryComments); ok {
+		values = *e
 	}
 	return
 }
 
-func withCheck(present bool, argv ...bool) (ok bool) {
-	ok = present && orCheck(argv...)
+func prepareBoundary(ctx *cli.Context) (size int, err error) {
+	value := ctx.String(gBoundaryFlag.Name)
+	if value == gBoundaryAuto {
+		return hrxutil.BoundaryAuto, nil
+	} else if size, err = strconv.Atoi(value); err != nil || size <= 0 {
+		size, err = 0, ErrBadBoundary
+	}
 	return
 }
 
-func prepareOptions(ctx *cli.Context) (opt *hrxutil.Options) {
-	return &hrxutil.Options{
-		All:        ctx.Bool(gAllFlag.Name),
-		Recurse:    ctx.Bool(gRecurseFlag.Name),
-		Boundary:   ctx.Int(gBoundaryFlag.Name),
-		PruneDir:   ctx.Bool(gPruneDirFlag.Name),
-		KeepEmpty:  ctx.Bool(gKeepEmptyFlag.Name),
-		TrimPrefix: ctx.String(gTrimPrefixFlag.Name),
+func prepareOverwrite(ctx *cli.Context) (policy string, err error) {
+	policy = hrxutil.OverwriteAlways
+	var found bool
+	for _, overwrite := range gOverwriteFlags {
+		if ctx.Bool(overwrite.flag.Name) {
+			if found {
+				err = ErrMustOverwrite
+				return
+			}
+			policy, found = overwrite.policy, true
+		}
 	}
+	return
 }
 
 func prepareOpMode(ctx *cli.Context) (op opMode, err error) {
-	fCreate, fExtract, fList := ctx.Bool(gCreateFlag.Name),
-		ctx.Bool(gExtractFlag.Name),
-		ctx.Bool(gListFlag.Name)
-	if present := fCreate; withCheck(present, fExtract, fList) {
-		err = ErrMustOpMode
-	} else if present {
-		op = opCreate
-	} else if present = fExtract; withCheck(present, fCreate, fList) {
-		err = ErrMustOpMode
-	} else if present {
-		op = opExtract
-	} else if present = fList; withCheck(present, fCreate, fExtract) {
-		err = ErrMustOpMode
-	} else if present {
-		op = opList
-	} else {
+	for _, mode := range gOpModeFlags {
+		if ctx.Bool(mode.flag.Name) {
+			if op != opError {
+				op, err = opError, ErrMustOpMode
+				return
+			}
+			op = mode.op
+		}
+	}
+	if op == opError {
 		err = ErrNeedOpMode
 	}
 	return
@@ -89,16 +168,14 @@ func action(ctx *cli.Context) (err error) {
 		_, _ = fmt.Fprintf(os.Stderr, "%s\n", AppUsageBrief)
 		os.Exit(0)
 	}
-	if ctx.Bool(gVerboseFlag.Name) || ctx.Bool(gListFlag.Name) {
-		hrxutil.Notifier = notify.New(notify.Info).Make()
-	}
-
 	var op opMode
 	if op, err = prepareOpMode(ctx); err != nil {
 		if op == opError && ctx.NumFlags() == 0 {
 			cli.ShowAppHelpAndExit(ctx, 0)
 		}
 		return
+	} else if _, err = prepareBoundary(ctx); err != nil {
+		return
 	}
 
 	argv := ctx.Args().Slice()
@@ -110,6 +187,18 @@ func action(ctx *cli.Context) (err error) {
 		return actionExtract(ctx, argv)
 	case opList:
 		return actionList(ctx, argv)
+	case opAppend:
+		return actionModify(ctx, argv, hrxutil.AppendContext)
+	case opUpdate:
+		return actionModify(ctx, argv, hrxutil.UpdateContext)
+	case opDelete:
+		return actionDelete(ctx, argv)
+	case opDiff:
+		return actionDiff(ctx, argv)
+	case opVerify:
+		return actionVerify(ctx)
+	case opCat:
+		return actionCat(ctx, argv)
 	case opError:
 	}
 
@@ -121,7 +210,11 @@ func actionList(ctx *cli.Context, argv []string) (err error) {
 		err = ErrNeedArchive
 		return
 	}
-	err = hrxutil.List(ctx.String(gFileFlag.Name), argv...)
+	if src := ctx.String(gFileFlag.Name); src == gStdioName {
+		err = hrxutil.ListFromContext(ctx.Context, prepareOptions(ctx), os.Stdin, argv...)
+	} else {
+		err = hrxutil.ListContext(ctx.Context, prepareOptions(ctx), src, argv...)
+	}
 	return
 }
 
@@ -137,14 +230,130 @@ func actionCreate(ctx *cli.Context, argv []string) (err error) {
 	} else {
 		dst = clPath.Base(argv[0]) + ".hrx"
 	}
-	_, err = hrxutil.Create(prepareOptions(ctx), dst, argv...)
+	if dst == gStdioName {
+		// the archive is the output, keep stdout clean
+		opt := prepareOptions(ctx)
+		opt.Notifier = opt.Notifier.ModifyOut(os.Stderr)
+		opt.Output = os.Stderr
+		if ctx.Bool(gStreamFlag.Name) {
+			_, err = hrxutil.CreateStreamToContext(ctx.Context, opt, os.Stdout, argv...)
+			return
+		}
+		_, _, err = hrxutil.CreateToContext(ctx.Context, opt, os.Stdout, argv...)
+		return
+	} else if ctx.Bool(gStreamFlag.Name) {
+		_, err = hrxutil.CreateStreamContext(ctx.Context, prepareOptions(ctx), dst, argv...)
+		return
+	}
+	_, _, err = hrxutil.CreateContext(ctx.Context, prepareOptions(ctx), dst, argv...)
+	return
+}
+
+func actionModify(ctx *cli.Context, argv []string, fn func(ctx context.Context, opt *hrxutil.Options, dst string, pathnames ...string) (a hrx.Archive, err error)) (err error) {
+	var dst string
+	if ctx.IsSet(gFileFlag.Name) {
+		if dst = ctx.String(gFileFlag.Name); !clPath.IsFile(dst) {
+			err = ErrFileNotFound
+			return
+		}
+	} else {
+		err = ErrNeedArchive
+		return
+	}
+	_, err = fn(ctx.Context, prepareOptions(ctx), dst, argv...)
+	return
+}
+
+func actionDelete(ctx *cli.Context, argv []string) (err error) {
+	var src string
+	if ctx.IsSet(gFileFlag.Name) {
+		if src = ctx.String(gFileFlag.Name); !clPath.IsFile(src) {
+			err = ErrFileNotFound
+			return
+		}
+	} else {
+		err = ErrNeedArchive
+		return
+	}
+	_, err = hrxutil.DeleteContext(ctx.Context, prepareOptions(ctx), src, argv...)
+	return
+}
+
+func actionDiff(ctx *cli.Context, argv []string) (err error) {
+	var src, dir string
+	if ctx.IsSet(gFileFlag.Name) {
+		if src = ctx.String(gFileFlag.Name); !clPath.IsFile(src) {
+			err = ErrFileNotFound
+			return
+		}
+	} else {
+		err = ErrNeedArchive
+		return
+	}
+
+	if ctx.IsSet(gDirFlag.Name) {
+		if dir = ctx.String(gDirFlag.Name); !clPath.IsDir(dir) {
+			err = ErrDirNotFound
+			return
+		}
+	} else {
+		dir = "."
+	}
+
+	var differences int
+	if differences, err = hrxutil.DiffContext(ctx.Context, prepareOptions(ctx), src, dir, argv...); err == nil && differences > 0 {
+		err = fmt.Errorf("%w: %d found", ErrDifferences, differences)
+	}
+	return
+}
+
+func actionVerify(ctx *cli.Context) (err error) {
+	// reading cannot be cancelled, interrupts stop the process instead
+	signal.Reset(os.Interrupt)
+	var src string
+	if ctx.IsSet(gFileFlag.Name) {
+		if src = ctx.String(gFileFlag.Name); !clPath.IsFile(src) {
+			err = ErrFileNotFound
+			return
+		}
+	} else {
+		err = ErrNeedArchive
+		return
+	}
+
+	var problems []*hrxutil.Problem
+	if problems, err = hrxutil.VerifyWith(prepareOptions(ctx), src); err == nil && len(problems) > 0 {
+		err = fmt.Errorf("%w: %d found", ErrProblems, len(problems))
+	}
+	return
+}
+
+func actionCat(ctx *cli.Context, argv []string) (err error) {
+	// reading cannot be cancelled, interrupts stop the process instead
+	signal.Reset(os.Interrupt)
+	var src string
+	if ctx.IsSet(gFileFlag.Name) {
+		if src = ctx.String(gFileFlag.Name); !clPath.IsFile(src) {
+			err = ErrFileNotFound
+			return
+		}
+	} else {
+		err = ErrNeedArchive
+		return
+	}
+
+	if ctx.Bool(gHeadersFlag.Name) {
+		err = hrxutil.CatWithHeaders(src, os.Stdout, argv...)
+		return
+	}
+	err = hrxutil.Cat(src, os.Stdout, argv...)
 	return
 }
 
 func actionExtract(ctx *cli.Context, argv []string) (err error) {
 	var src, dst string
 	if ctx.IsSet(gFileFlag.Name) {
-		if src = ctx.String(gFileFlag.Name); !clPath.IsFile(src) {
+		if src = ctx.String(gFileFlag.Name); src != gStdioName && !clPath.IsFile(src) {
 			err = ErrFileNotFound
 			return
 		}
@@ -162,6 +371,15 @@ func actionExtract(ctx *cli.Context, argv []string) (err error) {
 		dst = "."
 	}
 
-	err = hrxutil.Extract(prepareOptions(ctx), ctx.String(gFileFlag.Name), dst, argv...)
+	opt := prepareOptions(ctx)
+	if opt.Overwrite, err = prepareOverwrite(ctx); err != nil {
+		return
+	}
+
+	if src == gStdioName {
+		err = hrxutil.ExtractFromContext(ctx.Context, opt, os.Stdin, dst, argv...)
+		return
+	}
+	err = hrxutil.ExtractContext(ctx.Context, opt, src, dst, argv...)
 	return
 }
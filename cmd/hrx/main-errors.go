@@ -19,9 +19,13 @@ import (
 )
 
 var (
-	ErrNeedOpMode   = errors.New("missing one of -l, -c or -x")
-	ErrMustOpMode   = errors.New("only one of -l, -c or -x are allowed")
-	ErrFileNotFound = errors.New("-f is not found or not an archive")
-	ErrNeedArchive  = errors.New("missing -f archive")
-	ErrDirNotFound  = errors.New("-o is not found or not a directory")
+	ErrNeedOpMode    = errors.New("missing one of -l, -c, -A, -u, -d, -D, -W, -O or -x")
+	ErrMustOpMode    = errors.New("only one of -l, -c, -A, -u, -d, -D, -W, -O or -x are allowed")
+	ErrFileNotFound  = errors.New("-f is not found or not an archive")
+	ErrNeedArchive   = errors.New("missing -f archive")
+	ErrDirNotFound   = errors.New("-o is not found or not a directory")
+	ErrDifferences   = errors.New("archive and directory differ")
+	ErrProblems      = errors.New("archive is not valid")
+	ErrMustOverwrite = errors.New("only one of --keep-old-files, --skip-newer or --backup are allowed")
+	ErrBadBoundary   = errors.New("--boundary must be \"auto\" or a positive number")
 )
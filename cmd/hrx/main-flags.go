@@ -15,7 +15,11 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/urfave/cli/v2"
+
+	hrxutil "github.com/go-coreutils/hrx"
 )
 
 func init() {
@@ -53,7 +57,7 @@ var (
 	gFileFlag = &cli.StringFlag{
 		Category: "SETTINGS",
 		Name:     "archive",
-		Usage:    "specify the archive file",
+		Usage:    "specify the archive file, or - for stdin/stdout",
 		Aliases:  []string{"f"},
 	}
 	gDirFlag = &cli.StringFlag{
@@ -87,11 +91,127 @@ var (
 		Usage:    "include empty files and directories",
 		Aliases:  []string{"k"},
 	}
-	gBoundaryFlag = &cli.IntFlag{
+	gIncludeFlag = &cli.StringSliceFlag{
+		Category: "SETTINGS",
+		Name:     "include",
+		Usage:    "only select pathnames matching the glob pattern (repeatable)",
+		Aliases:  []string{"I"},
+	}
+	gExcludeFlag = &cli.StringSliceFlag{
+		Category: "SETTINGS",
+		Name:     "exclude",
+		Usage:    "skip pathnames matching the glob pattern (repeatable)",
+		Aliases:  []string{"X"},
+	}
+	gNoIgnoreFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "no-ignore",
+		Usage:    "do not honour .hrxignore files when walking directories",
+	}
+	gGitIgnoreFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "git-ignore",
+		Usage:    "also honour .gitignore files when walking directories",
+	}
+	gUnifiedFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "unified",
+		Usage:    "include a unified diff of differing contents with --diff",
+		Aliases:  []string{"U"},
+	}
+	gHeadersFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "headers",
+		Usage:    "precede each entry with a header line with --to-stdout",
+		Aliases:  []string{"H"},
+	}
+	gBoundaryFlag = &cli.StringFlag{
 		Category: "SETTINGS",
 		Name:     "boundary",
-		Usage:    "specify the entry boundary size",
+		Usage:    "specify the entry boundary size, or auto to avoid nested archives",
 		Aliases:  []string{"b"},
+		Value:    gBoundaryAuto,
+	}
+	gMaxSizeFlag = &cli.Int64Flag{
+		Category: "SETTINGS",
+		Name:     "max-size",
+		Usage:    "skip files larger than the given number of bytes",
+	}
+	gStrictFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "strict",
+		Usage:    "stop with an error instead of skipping any files",
+	}
+	gUnsafePathsFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "unsafe-paths",
+		Usage:    "allow extracting outside of the destination (trusted archives only)",
+	}
+	gKeepOldFilesFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "keep-old-files",
+		Usage:    "do not replace existing files when extracting",
+	}
+	gSkipNewerFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "skip-newer",
+		Usage:    "do not replace existing files that are newer than the archive",
+	}
+	gBackupFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "backup",
+		Usage:    "rename existing files to numbered backups before replacing",
+	}
+	gDryRunFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "dry-run",
+		Usage:    "report what would be archived, extracted or deleted, without writing",
+		Aliases:  []string{"n"},
+	}
+	gWorkersFlag = &cli.IntFlag{
+		Category: "SETTINGS",
+		Name:     "workers",
+		Usage:    "number of files to read concurrently when walking directories",
+		Value:    1,
+	}
+	gSortFlag = &cli.StringFlag{
+		Category: "SETTINGS",
+		Name:     "sort",
+		Usage:    "order of the entries created or listed: none, name, natural, mtime or size",
+		Value:    hrxutil.SortNone,
+	}
+	gCommentFlag = &cli.StringFlag{
+		Category: "SETTINGS",
+		Name:     "comment",
+		Usage:    "set the archive comment when creating or modifying an archive",
+	}
+	gCommentFileFlag = &cli.StringFlag{
+		Category: "SETTINGS",
+		Name:     "comment-file",
+		Usage:    "set the archive comment to the contents of the given file",
+	}
+	gEntryCommentFlag = &cli.GenericFlag{
+		Category: "SETTINGS",
+		Name:     "entry-comment",
+		Usage:    "set the comment of entries matching the glob pattern, as PATTERN=TEXT (repeatable)",
+		Value:    &entryComments{},
+	}
+	gStreamFlag = &cli.BoolFlag{
+		Category: "SETTINGS",
+		Name:     "stream",
+		Usage:    "write each file to the new archive as it is read, for very large trees",
+	}
+	gFormatFlag = &cli.StringFlag{
+		Category: "SETTINGS",
+		Name:     "format",
+		Usage:    "output format of the summary: table, json, ndjson or csv",
+		Value:    hrxutil.FormatTable,
+	}
+	gBinaryFlag = &cli.StringFlag{
+		Category: "SETTINGS",
+		Name:     "binary",
+		Usage:    "how to handle non-text files: skip, error or base64",
+		Value:    hrxutil.BinarySkip,
 	}
 
 	gListFlag = &cli.BoolFlag{
@@ -106,10 +226,16 @@ var (
 		Usage:    "create a new archive",
 		Aliases:  []string{"c"},
 	}
+	gAppendFlag = &cli.BoolFlag{
+		Category: "OPERATIONS",
+		Name:     "append",
+		Usage:    "add to or replace within an existing archive",
+		Aliases:  []string{"A"},
+	}
 	gUpdateFlag = &cli.BoolFlag{
 		Category: "OPERATIONS",
 		Name:     "update",
-		Usage:    "add to an existing archive",
+		Usage:    "add to or replace changed within an existing archive",
 		Aliases:  []string{"u"},
 	}
 	gDeleteFlag = &cli.BoolFlag{
@@ -118,6 +244,24 @@ var (
 		Usage:    "remove from an existing archive",
 		Aliases:  []string{"d"},
 	}
+	gDiffFlag = &cli.BoolFlag{
+		Category: "OPERATIONS",
+		Name:     "diff",
+		Usage:    "compare an existing archive with a directory",
+		Aliases:  []string{"D"},
+	}
+	gVerifyFlag = &cli.BoolFlag{
+		Category: "OPERATIONS",
+		Name:     "verify",
+		Usage:    "check an existing archive for problems",
+		Aliases:  []string{"W"},
+	}
+	gCatFlag = &cli.BoolFlag{
+		Category: "OPERATIONS",
+		Name:     "to-stdout",
+		Usage:    "write archive entry contents to stdout",
+		Aliases:  []string{"O", "cat"},
+	}
 	gExtractFlag = &cli.BoolFlag{
 		Category: "OPERATIONS",
 		Name:     "extract",
@@ -125,3 +269,17 @@ var (
 		Aliases:  []string{"x"},
 	}
 )
+
+// entryComments is the --entry-comment flag value, which unlike a
+// cli.StringSliceFlag does not split values on commas, as the TEXT of a
+// PATTERN=TEXT value may contain them
+type entryComments []string
+
+func (e *entryComments) Set(value string) (err error) {
+	*e = append(*e, value)
+	return
+}
+
+func (e *entryComments) String() (value string) {
+	return strings.Join(*e, ", ")
+}
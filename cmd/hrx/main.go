@@ -15,8 +15,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"sort"
 
 	"github.com/urfave/cli/v2"
@@ -27,10 +29,16 @@ import (
 var (
 	AppName       = "hrx"
 	AppVersion    = "v0.5.1"
-	AppUsageText  = `hrx [global options] <-l|-c|-x> -f <archive> [pathnames...]`
+	AppUsageText  = `hrx [global options] <-l|-c|-A|-u|-d|-D|-W|-O|-x> -f <archive> [pathnames...]`
 	AppUsageBrief = "usage: " + AppUsageText + "\n" + `       hrx --help
        hrx --list -f existing.hrx
        hrx --create -f new.hrx <path> [paths...]
+       hrx --append -f existing.hrx <path> [paths...]
+       hrx --update -f existing.hrx <path> [paths...]
+       hrx --delete -f existing.hrx <pathname> [pathnames...]
+       hrx --diff -f existing.hrx [-o directory] [pathnames...]
+       hrx --verify -f existing.hrx
+       hrx --to-stdout -f existing.hrx [pathnames...]
        hrx --extract -f existing.hrx [pathnames...]`
 	AppDescription = `hrx is like the tar command except that the archives are human readable.
 
@@ -44,13 +52,88 @@ Note: this project has no affiliation with Google and is entirely independent.
 
 OPERATIONS:
 
-  There are currently three operational modes that can be performed:
+  There are currently nine operational modes that can be performed:
 
-    --list     (-l)
-    --create   (-c)
-    --extract  (-x)
+    --list       (-l)
+    --create     (-c)
+    --append     (-A)
+    --update     (-u)
+    --delete     (-d)
+    --diff       (-D)
+    --verify     (-W)
+    --to-stdout  (-O)
+    --extract    (-x)
 
-  All modes require the --archive (-f) flag.
+  All modes require the --archive (-f) flag. The --list, --create and
+  --extract modes also accept "-" as the archive, meaning stdin or stdout.
+
+  The --delete mode refuses to remove every entry of the archive, as an
+  archive without any entries cannot be read back.
+
+  The --diff mode exits with a non-zero status when any differences are
+  found, making it suitable for checking that fixtures are up-to-date.
+
+  The --verify mode reports every problem found with the archive, along with
+  the line number and pathname of each, and exits with a non-zero status
+  when the archive is not valid.
+
+  When walking directories, any .hrxignore files found are read using the
+  same rules as .gitignore files and the matching files and directories are
+  skipped. Use --no-ignore to disable this and --git-ignore to also honour
+  any .gitignore files found.
+
+  Files that are not valid UTF-8 text are skipped by default. Use
+  --binary=error to stop instead, or --binary=base64 to store them base64
+  encoded with an "encoding: base64" entry comment, which --extract decodes.
+
+  Every file skipped while walking directories, or left out by --include and
+  --exclude, is listed along with the reason when --verbose is used. Use
+  --strict to stop with an error instead of skipping any other files.
+
+  The --extract mode refuses to write any entry outside of the destination
+  directory, including through existing symlinks, reporting the offending
+  entry instead. Use --unsafe-paths to allow this for trusted archives.
+
+  Existing files are replaced when extracting, unless one of --keep-old-files,
+  --skip-newer (keep files newer than the archive) or --backup (rename them to
+  numbered backups, such as "file.txt.~1~") is given.
+
+  The --dry-run (-n) flag shows what the --create, --append, --update,
+  --delete and --extract modes would do, with the projected sizes, without
+  writing anything.
+
+  The --format flag changes the summary of the --list, --create, --append,
+  --update, --diff and --extract modes from the human readable table to
+  records written to stdout as json, ndjson or csv, with the pathname, type,
+  size in bytes, comment, operation and destination of each entry. Any
+  boundary shown in the table is the last record, with the "boundary" type
+  and operation. These records are written even without --verbose.
+
+  Interrupting (Ctrl+C) any mode stops it promptly. No archive is written or
+  changed by an interrupted --create, --append, --update or --delete and the
+  files and directories written by an interrupted --extract are removed again.
+
+  The --boundary (-b) flag defaults to "auto", which uses the smallest
+  boundary size from 5 (<=====>) that no nested .hrx file uses. The boundary
+  used is shown in the --create summary. Any other file or comment with a
+  line starting like a boundary, of any size, cannot be read back and stops
+  --create, --append and --update with an error, unless --binary=base64 is
+  used to store such files encoded.
+
+  The --sort flag orders the entries added by --create, --append and
+  --update, or shown by --list, by name, natural (numbers compared by value),
+  mtime (oldest first) or size (smallest first). With any order other than
+  the default of none, re-creating the same files always results in the
+  same archive, regardless of the order of the paths given.
+
+  The --comment and --comment-file flags set the archive comment and the
+  --entry-comment flag sets the comment of each entry matching the PATTERN
+  of a PATTERN=TEXT value, using the same patterns as --include. These are
+  used by the --create, --append and --update modes.
+
+  The --stream flag changes --create to write each file to the new archive
+  as soon as it is read, instead of holding the whole archive in memory. The
+  archive written is the same, only files are read one at a time.
 
 EXAMPLES:
 
@@ -61,9 +144,47 @@ EXAMPLES:
   # with any extension
   hrx -cf custom-name.hrx files.*
 
+  # add "extra.txt" to an existing archive named "custom-name.hrx", replacing
+  # any "extra.txt" entry already present only if the contents have changed
+  hrx -uf custom-name.hrx extra.txt
+
+  # remove the "docs/" directory entry and everything under it from an
+  # existing archive named "custom-name.hrx"
+  hrx -df custom-name.hrx docs/
+
+  # compare an archive named "custom-name.hrx" (created with --prune-dir)
+  # with the "custom-name" directory, showing the changed contents
+  hrx -DPUf custom-name.hrx -o custom-name
+
+  # print the contents of "input.scss" from an archive named "custom-name.hrx"
+  hrx -Of custom-name.hrx input.scss
+
   # extract an archive named "custom-name.hrx" into a sub-directory named
   # "custom-name"
   hrx -xf custom-name.hrx
+
+  # create a new archive named "src.hrx" from the "src" directory, without
+  # any log files and then extract only the stylesheets from it
+  hrx -cf src.hrx --exclude "*.log" src
+  hrx -xf src.hrx --include "**/*.css"
+
+  # create a new archive named "repo.hrx" from the "repo" directory, skipping
+  # everything listed in any .gitignore files within it
+  hrx -cf repo.hrx --git-ignore repo
+
+  # create a new archive named "assets.hrx" from the "assets" directory,
+  # including any images as base64 encoded entries
+  hrx -cf assets.hrx --binary=base64 assets
+
+  # show what extracting an archive named "custom-name.hrx" would write,
+  # without writing anything
+  hrx -xnf custom-name.hrx
+
+  # list the contents of an archive named "custom-name.hrx" as json
+  hrx -lf custom-name.hrx --format=json
+
+  # extract an archive read from stdin into a directory named "out"
+  git show HEAD:fixtures.hrx | hrx -x -f - -o out
 `
 )
 
@@ -85,13 +206,38 @@ var (
 			gFileFlag,
 			gUsageFlag,
 			gCreateFlag,
-			//gUpdateFlag,
-			//gDeleteFlag,
+			gAppendFlag,
+			gUpdateFlag,
+			gDeleteFlag,
+			gDiffFlag,
+			gVerifyFlag,
+			gCatFlag,
 			gExtractFlag,
 			gRecurseFlag,
 			gVerboseFlag,
 			gPruneDirFlag,
 			gBoundaryFlag,
+			gBinaryFlag,
+			gMaxSizeFlag,
+			gStrictFlag,
+			gUnsafePathsFlag,
+			gKeepOldFilesFlag,
+			gSkipNewerFlag,
+			gBackupFlag,
+			gDryRunFlag,
+			gFormatFlag,
+			gWorkersFlag,
+			gStreamFlag,
+			gSortFlag,
+			gCommentFlag,
+			gCommentFileFlag,
+			gEntryCommentFlag,
+			gUnifiedFlag,
+			gIncludeFlag,
+			gExcludeFlag,
+			gNoIgnoreFlag,
+			gGitIgnoreFlag,
+			gHeadersFlag,
 			gKeepEmptyFlag,
 			gTrimPrefixFlag,
 		},
@@ -108,8 +254,12 @@ func init() {
 
 func main() {
 	sort.Sort(cli.FlagsByName(gApp.Flags))
-	if err := gApp.Run(os.Args); err != nil {
+	// cancel the operation in progress on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := gApp.RunContext(ctx, os.Args); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, fmt.Sprintf("error: %v\n", err))
+		stop()
 		os.Exit(1)
 	}
 }
@@ -11,6 +11,8 @@ require (
 	github.com/go-corelibs/notify v1.0.2
 	github.com/go-corelibs/path v1.4.1
 	github.com/go-corelibs/tdata v1.3.0
+	github.com/hexops/gotextdiff v1.0.3
+	github.com/maruel/natural v1.1.1
 	github.com/smartystreets/goconvey v1.8.1
 	github.com/urfave/cli/v2 v2.27.2
 )
@@ -26,7 +28,6 @@ require (
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
 	github.com/iancoleman/strcase v0.3.0 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
-	github.com/maruel/natural v1.1.1 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/smarty/assertions v1.15.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20240312152122-5f08fbb34913 // indirect
@@ -0,0 +1,104 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	// BinarySkip is the Options.Binary mode that skips non-text files, this
+	// is the default
+	BinarySkip = "skip"
+	// BinaryError is the Options.Binary mode that stops with an error when a
+	// non-text file is found
+	BinaryError = "error"
+	// BinaryBase64 is the Options.Binary mode that stores non-text files as
+	// base64 encoded entries, marked with an EncodingBase64 comment line
+	BinaryBase64 = "base64"
+)
+
+const (
+	// EncodingBase64 is the entry comment line marking an entry body as
+	// being base64 encoded
+	EncodingBase64 = "encoding: base64"
+
+	// gBase64LineWidth is the maximum line length of encoded entry bodies
+	gBase64LineWidth = 76
+)
+
+func validateBinary(opt *Options) (err error) {
+	switch opt.Binary {
+	case "", BinarySkip, BinaryError, BinaryBase64:
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidBinaryMode, opt.Binary)
+	}
+	return
+}
+
+// isBase64Comment reports whether the entry comment given contains the
+// EncodingBase64 marker line
+func isBase64Comment(comment string) (encoded bool) {
+	for _, line := range strings.Split(comment, "\n") {
+		if strings.TrimSpace(line) == EncodingBase64 {
+			return true
+		}
+	}
+	return
+}
+
+// setBase64Comment returns the entry comment given with the EncodingBase64
+// marker line added as the first line, or removed if not `encoded`
+func setBase64Comment(comment string, encoded bool) (modified string) {
+	var lines []string
+	if encoded {
+		lines = append(lines, EncodingBase64)
+	}
+	if comment != "" {
+		for _, line := range strings.Split(comment, "\n") {
+			if strings.TrimSpace(line) != EncodingBase64 {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// encodeBase64Body returns the data given base64 encoded and wrapped into
+// lines of gBase64LineWidth
+func encodeBase64Body(data []byte) (body string) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf strings.Builder
+	for len(encoded) > gBase64LineWidth {
+		buf.WriteString(encoded[:gBase64LineWidth] + "\n")
+		encoded = encoded[gBase64LineWidth:]
+	}
+	buf.WriteString(encoded + "\n")
+	return buf.String()
+}
+
+// decodeEntryBody returns the actual file contents of an entry, decoding the
+// body when the comment has the EncodingBase64 marker line
+func decodeEntryBody(body, comment string) (data []byte, err error) {
+	if !isBase64Comment(comment) {
+		return []byte(body), nil
+	}
+	if data, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), "")); err != nil {
+		err = fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return
+}
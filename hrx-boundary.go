@@ -0,0 +1,171 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// BoundaryAuto is the Options.Boundary value for choosing the boundary size
+// from the contents archived
+const BoundaryAuto = 0
+
+// boundaryScan is the set of boundary sizes found at the start of any line
+// within the contents scanned
+type boundaryScan map[int]struct{}
+
+// add scans each line of the `text` for anything that looks like a boundary
+func (b boundaryScan) add(text string) {
+	for text != "" {
+		line := text
+		if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+			line, text = text[:idx], text[idx+1:]
+		} else {
+			text = ""
+		}
+		if size := boundarySize(line); size > 0 {
+			b[size] = struct{}{}
+		}
+	}
+}
+
+// addEntry scans the body and comment of the entry `pathname`. When parsed,
+// any line that looks like a boundary, of any size, starts a new entry unless
+// it is within a nested archive of a different size, so ErrBoundaryCollision
+// is returned for such lines in any comment or in the body of any entry that
+// is not a nested archive
+func (b boundaryScan) addEntry(pathname, body, comment string) (err error) {
+	if hasBoundaryLine(comment) || (!strings.HasSuffix(pathname, ".hrx") && hasBoundaryLine(body)) {
+		return fmt.Errorf("%w: %q", ErrBoundaryCollision, pathname)
+	}
+	b.add(body)
+	return
+}
+
+// addArchive scans all the bodies and comments of the archive given
+func (b boundaryScan) addArchive(a hrx.Archive) (err error) {
+	for _, entry := range a.Entries() {
+		if err = b.addEntry(entry.GetPathname(), entry.GetBody(), entry.GetComment()); err != nil {
+			return
+		}
+	}
+	if comment, ok := a.GetComment(); ok {
+		err = b.addEntry(a.FileName(), "", comment)
+	}
+	return
+}
+
+// pick returns the smallest boundary size, starting from hrx.DefaultBoundary,
+// that was not found in any of the nested archives scanned
+func (b boundaryScan) pick() (size int) {
+	for size = hrx.DefaultBoundary; ; size++ {
+		if _, present := b[size]; !present {
+			return
+		}
+	}
+}
+
+// boundarySize returns the number of equal signs when the `line` starts with
+// a boundary, such as "<===>", and zero otherwise
+func boundarySize(line string) (size int) {
+	if !strings.HasPrefix(line, "<") {
+		return
+	}
+	for size = 1; size < len(line) && line[size] == '='; size++ {
+	}
+	if size > 1 && size < len(line) && line[size] == '>' {
+		return size - 1
+	}
+	return 0
+}
+
+// hasBoundaryLine reports whether any line of the `text` starts with a
+// boundary of any size
+func hasBoundaryLine(text string) (found bool) {
+	scan := make(boundaryScan)
+	scan.add(text)
+	return len(scan) > 0
+}
+
+// boundaryLine returns the boundary of the size given, such as "<=====>"
+func boundaryLine(size int) (boundary string) {
+	return "<" + strings.Repeat("=", size) + ">"
+}
+
+// copyEntries sets all the entries of the `src` archive within the `dst`
+// archive, without reporting any of them
+func copyEntries(dst, src hrx.Archive) (err error) {
+	dst.SetReporter(nil)
+	for _, entry := range src.Entries() {
+		if err = setEntry(dst, nil, entry.GetPathname(), entry.GetBody(), entry.GetComment()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// settleBoundary makes sure that the archive `a` parses back into the same
+// entries, returning ErrBoundaryCollision otherwise. Any contents that look
+// like a boundary are refused, see boundaryScan.addEntry, except for nested
+// archives. When a nested archive collides with the boundary and the
+// Options.Boundary is BoundaryAuto, the archive is rebuilt with a boundary
+// that does not
+func settleBoundary(s *session, a hrx.Archive) (settled hrx.Archive, err error) {
+	settled = a
+	scan := make(boundaryScan)
+	if err = scan.addArchive(a); err != nil {
+		return
+	} else if _, collides := scan[a.GetBoundary()]; collides {
+		if s.opt.Boundary != BoundaryAuto {
+			err = fmt.Errorf("%w: %q", ErrBoundaryCollision, a.FileName())
+			return
+		}
+		size := scan.pick()
+		settled = hrx.New(a.FileName(), "")
+		_ = settled.SetBoundary(size)
+		if err = copyEntries(settled, a); err != nil {
+			return
+		} else if comment, ok := a.GetComment(); ok {
+			settled.SetComment(comment)
+		}
+		settled.SetReporter(s.report)
+		s.report(a.FileName(), "", hrx.OpBoundary, a.GetBoundary(), size)
+	}
+
+	// header-like lines of any size end a plain entry when parsed
+	var parsed hrx.Archive
+	if text := settled.String(); strings.TrimSpace(text) == "" {
+		// nothing to parse, such as a dry run of an empty directory
+		return
+	} else if parsed, err = hrx.ParseData(settled.FileName(), text); err != nil {
+		err = fmt.Errorf("%w: %q: %w", ErrBoundaryCollision, settled.FileName(), err)
+		return
+	}
+	original, entries := settled.Entries(), parsed.Entries()
+	if len(entries) != len(original) {
+		err = fmt.Errorf("%w: %q", ErrBoundaryCollision, settled.FileName())
+		return
+	}
+	for idx, entry := range entries {
+		if entry.GetPathname() != original[idx].GetPathname() || entry.GetBody() != original[idx].GetBody() {
+			err = fmt.Errorf("%w: %q", ErrBoundaryCollision, original[idx].GetPathname())
+			return
+		}
+	}
+	return
+}
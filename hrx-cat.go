@@ -0,0 +1,85 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-corelibs/hrx"
+	"github.com/go-corelibs/tdata"
+)
+
+// Cat writes the body contents of the file entries within an existing `src`
+// archive file to the given io.Writer, in the order they are found within
+// the archive. If any `pathnames` are given, only those entries are written
+// and all of them must be present within the archive
+func Cat(src string, w io.Writer, pathnames ...string) (err error) {
+	return catEntries(src, w, false, pathnames...)
+}
+
+// CatWithHeaders is like Cat except that each body is preceded by a header
+// line naming the entry, in the same style as the head(1) command uses when
+// given multiple files
+func CatWithHeaders(src string, w io.Writer, pathnames ...string) (err error) {
+	return catEntries(src, w, true, pathnames...)
+}
+
+func catEntries(src string, w io.Writer, headers bool, pathnames ...string) (err error) {
+	var a hrx.Archive
+	if a, err = prepareExistingSrc(src); err != nil {
+		return
+	}
+
+	for _, name := range pathnames {
+		if a.Entry(name) == nil {
+			err = fmt.Errorf("%w: %q", hrx.ErrNotFound, name)
+			return
+		}
+	}
+
+	var count int
+	tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
+	for _, entry := range a.Entries() {
+		pathname := entry.GetPathname()
+		if !entry.IsFile() || tc.NotPresent(pathname) {
+			continue
+		}
+
+		var data []byte
+		if data, err = decodeEntryBody(entry.GetBody(), entry.GetComment()); err != nil {
+			err = fmt.Errorf("%w: %q", err, pathname)
+			return
+		}
+		body := string(data)
+		if headers {
+			if count > 0 {
+				_, _ = io.WriteString(w, "\n")
+			}
+			if body != "" && !strings.HasSuffix(body, "\n") {
+				body += "\n"
+			}
+			if _, err = fmt.Fprintf(w, "==> %s <==\n", pathname); err != nil {
+				return
+			}
+		}
+		if _, err = io.WriteString(w, body); err != nil {
+			return
+		}
+		count += 1
+	}
+	return
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+func validateComments(opt *Options) (err error) {
+	if opt.Comment != "" && opt.CommentFile != "" {
+		return fmt.Errorf("%w: both a comment and a comment file given", ErrInvalidComment)
+	}
+	for _, value := range opt.EntryComments {
+		pattern, _, found := strings.Cut(value, "=")
+		if !found || strings.Trim(pattern, "/") == "" {
+			return fmt.Errorf("%w: %q is not PATTERN=TEXT", ErrInvalidComment, value)
+		}
+		for _, part := range strings.Split(pattern, "/") {
+			if _, err = path.Match(part, ""); err != nil {
+				return fmt.Errorf("%w: %q", err, pattern)
+			}
+		}
+	}
+	return
+}
+
+// archiveComment returns the archive comment of the Options given, read from
+// the Options.CommentFile when set. `ok` is false when there is no comment
+func archiveComment(opt *Options) (comment string, ok bool, err error) {
+	if comment = opt.Comment; opt.CommentFile != "" {
+		if err = validateExistingFile(opt.CommentFile); err != nil {
+			return
+		}
+		var data []byte
+		if data, err = os.ReadFile(opt.CommentFile); err != nil {
+			return
+		} else if !utf8.Valid(data) {
+			err = fmt.Errorf("%w: %q", ErrNotPlainText, opt.CommentFile)
+			return
+		}
+		comment = string(data)
+	}
+	ok = comment != ""
+	return
+}
+
+// sameComment reports whether the `existing` comment, which has a trailing
+// newline when parsed from an archive, is the same as the `text` given
+func sameComment(existing, text string) (same bool) {
+	return strings.TrimSuffix(existing, "\n") == strings.TrimSuffix(text, "\n")
+}
+
+// entryComment returns the text of the last Options.EntryComments pattern
+// matching the `pathname`. `ok` is false when no patterns match
+func entryComment(opt *Options, pathname string) (comment string, ok bool) {
+	pathname = strings.TrimSuffix(pathname, "/")
+	for _, value := range opt.EntryComments {
+		if pattern, text, found := strings.Cut(value, "="); found && matchGlob(pattern, pathname) {
+			comment, ok = text, true
+		}
+	}
+	return
+}
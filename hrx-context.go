@@ -0,0 +1,30 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+)
+
+// checkContext returns the session context error, wrapped with the
+// `pathname` being processed, once the context is done
+func (s *session) checkContext(pathname string) (err error) {
+	select {
+	case <-s.ctx.Done():
+		err = fmt.Errorf("%w: %q", s.ctx.Err(), pathname)
+	default:
+	}
+	return
+}
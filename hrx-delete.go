@@ -0,0 +1,109 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// Delete removes the given `pathnames` from the existing `src` archive file
+// and writes the archive back in-place, keeping the boundary and any
+// archive comment as-is. Pathnames ending with a slash are treated as
+// directories and every entry under that directory is removed as well. All
+// `pathnames` must be present within the archive and at least one entry must
+// remain, as an archive without entries cannot be parsed, otherwise no
+// changes are made and an error is returned
+func Delete(src string, pathnames ...string) (a hrx.Archive, err error) {
+	return DeleteContext(context.Background(), nil, src, pathnames...)
+}
+
+// DeleteContext is like Delete except that the summary is reported according
+// to the Options given and the `ctx` is checked before the archive is
+// written. When the `ctx` is done, the `src` archive is left as-is and the
+// `ctx` error is returned wrapped with the `src` pathname. With
+// Options.DryRun, the summary is reported without writing the archive
+func DeleteContext(ctx context.Context, opt *Options, src string, pathnames ...string) (a hrx.Archive, err error) {
+	if len(pathnames) == 0 {
+		err = ErrPathRequired
+		return
+	} else if a, err = prepareExistingSrc(src); err != nil {
+		a = nil
+		return
+	}
+	s := newSession(ctx, opt)
+
+	var removals []string
+	for _, name := range pathnames {
+		var found []string
+		if found = findDeletions(a, name); len(found) == 0 {
+			err = fmt.Errorf("%w: %q", hrx.ErrNotFound, name)
+			a = nil
+			return
+		}
+		removals = append(removals, found...)
+	}
+	if countUnique(removals) == len(a.List()) {
+		err = fmt.Errorf("%w: %q", ErrDeletesAll, src)
+		a = nil
+		return
+	}
+
+	for _, pathname := range removals {
+		if entry := a.Entry(pathname); entry != nil {
+			a.Delete(pathname)
+			size, _ := entry.Size()
+			s.report(src, pathname, hrx.OpDeleted, size)
+		}
+	}
+
+	if err = s.checkContext(src); err != nil {
+		a = nil
+	} else if s.opt.DryRun {
+		s.printSummarySize(a, hrx.OpDeleted, src, archiveSize(a))
+	} else if err = a.WriteFile(src); err != nil {
+		a = nil
+	} else {
+		s.printSummary(a, hrx.OpDeleted, src)
+	}
+	return
+}
+
+func countUnique(values []string) (count int) {
+	unique := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		unique[value] = struct{}{}
+	}
+	return len(unique)
+}
+
+func findDeletions(a hrx.Archive, name string) (found []string) {
+	if strings.HasSuffix(name, "/") {
+		// directory name, include everything under it
+		for _, pathname := range a.List() {
+			if strings.HasPrefix(pathname, name) {
+				found = append(found, pathname)
+			}
+		}
+		return
+	}
+	if entry := a.Entry(name); entry != nil {
+		found = append(found, entry.GetPathname())
+	}
+	return
+}
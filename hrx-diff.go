@@ -0,0 +1,131 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+
+	"github.com/go-corelibs/hrx"
+	clPath "github.com/go-corelibs/path"
+	"github.com/go-corelibs/tdata"
+)
+
+// Diff compares the file entries of an existing `src` archive with the
+// files found within the `dir` directory, according to the Options given.
+// Files within `dir` are named the same way that Create would name them,
+// so that comparing an archive with the directory it was created from
+// reports no differences. If any `pathnames` are given, only those
+// pathnames are compared
+//
+// Diff reports each entry that is only present in the archive, each file
+// that is only present in the directory and each entry with contents that
+// differ from the file on disk. The number of differences found is
+// returned
+func Diff(opt *Options, src, dir string, pathnames ...string) (differences int, err error) {
+	return DiffContext(context.Background(), opt, src, dir, pathnames...)
+}
+
+// DiffContext is like Diff except that the `ctx` is checked between each
+// entry compared and when the `ctx` is done, the `ctx` error is returned
+// wrapped with the pathname being compared
+func DiffContext(ctx context.Context, opt *Options, src, dir string, pathnames ...string) (differences int, err error) {
+	var a hrx.Archive
+	if a, err = prepareExistingSrc(src); err != nil {
+		return
+	} else if !clPath.IsDir(dir) {
+		err = fmt.Errorf("%w: %q", ErrDirNotFound, dir)
+		return
+	}
+	s := newSession(ctx, opt)
+	opt = s.opt
+	if err = validateOptions(opt); err != nil {
+		return
+	}
+
+	var files []string
+	if opt.Recurse {
+		files, err = clPath.ListAllFiles(dir, opt.All)
+	} else {
+		files, err = clPath.ListFiles(dir, opt.All)
+	}
+	if err != nil {
+		return
+	}
+	files, _ = filterIgnored(gOsSource, opt, dir, files)
+
+	tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
+	lookup := make(map[string]string)
+	var order []string
+	for _, file := range files {
+		if name := preparePath(opt, file); !tc.NotPresent(name) && isSelected(opt, name) {
+			lookup[name] = file
+			order = append(order, name)
+		}
+	}
+
+	var unified []string
+	for _, entry := range a.Entries() {
+		pathname := entry.GetPathname()
+		if !entry.IsFile() || tc.NotPresent(pathname) || !isSelected(opt, pathname) {
+			continue
+		} else if err = s.checkContext(pathname); err != nil {
+			return
+		}
+
+		file, present := lookup[pathname]
+		if !present {
+			differences += 1
+			s.report(src, pathname, OpArchiveOnly)
+			continue
+		}
+		delete(lookup, pathname)
+
+		var data, contents []byte
+		if data, err = os.ReadFile(file); err != nil {
+			return
+		} else if contents, err = decodeEntryBody(entry.GetBody(), entry.GetComment()); err != nil {
+			err = fmt.Errorf("%w: %q", err, pathname)
+			return
+		}
+		if body := string(contents); body != string(data) {
+			differences += 1
+			s.report(src, pathname, OpDiffers, file)
+			if opt.Unified && utf8.ValidString(body) && utf8.Valid(data) {
+				edits := myers.ComputeEdits(span.URIFromPath(pathname), body, string(data))
+				unified = append(unified, fmt.Sprint(gotextdiff.ToUnified(src+":"+pathname, file, body, edits)))
+			}
+		}
+	}
+
+	for _, name := range order {
+		if _, present := lookup[name]; present {
+			differences += 1
+			s.report(dir, name, OpDirOnly, lookup[name])
+		}
+	}
+
+	s.printSummary(a, OpCompared, src)
+	for _, text := range unified {
+		s.notifier.Info("%s", text)
+	}
+	return
+}
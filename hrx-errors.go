@@ -23,4 +23,20 @@ var (
 	ErrNotPlainText = errors.New("not a plain text file")
 	ErrPathRequired = errors.New("at least one path is required")
 	ErrFileNotFound = errors.New("file not found")
+	ErrDirNotFound  = errors.New("directory not found")
+	ErrNotDirectory = errors.New("not a directory")
+	ErrIgnored      = errors.New("ignored by pattern")
+	ErrNotSelected  = errors.New("not selected by pattern")
+	ErrTooLarge     = errors.New("file too large")
+	ErrUnsafePath   = errors.New("unsafe path")
+	ErrDeletesAll   = errors.New("cannot delete every entry")
+
+	ErrInconsistentBoundary = errors.New("inconsistent boundary")
+	ErrBoundaryCollision    = errors.New("contents collide with the boundary")
+	ErrInvalidBinaryMode    = errors.New("invalid binary mode")
+	ErrInvalidEncoding      = errors.New("invalid entry encoding")
+	ErrInvalidOverwrite     = errors.New("invalid overwrite policy")
+	ErrInvalidFormat        = errors.New("invalid output format")
+	ErrInvalidSort          = errors.New("invalid sort order")
+	ErrInvalidComment       = errors.New("invalid comment")
 )
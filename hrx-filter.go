@@ -0,0 +1,94 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+func validatePatterns(opt *Options) (err error) {
+	for _, patterns := range [][]string{opt.Include, opt.Exclude} {
+		for _, pattern := range patterns {
+			for _, part := range strings.Split(pattern, "/") {
+				if _, err = path.Match(part, ""); err != nil {
+					err = fmt.Errorf("%w: %q", err, pattern)
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// isSelected reports whether the given pathname passes the Include and
+// Exclude patterns of the Options given
+func isSelected(opt *Options, pathname string) (selected bool) {
+	pathname = strings.TrimSuffix(pathname, "/")
+	if selected = len(opt.Include) == 0; !selected {
+		for _, pattern := range opt.Include {
+			if selected = matchGlob(pattern, pathname); selected {
+				break
+			}
+		}
+	}
+	if selected {
+		for _, pattern := range opt.Exclude {
+			if matchGlob(pattern, pathname) {
+				return false
+			}
+		}
+	}
+	return
+}
+
+// matchGlob reports whether the pathname matches the glob pattern given.
+// Patterns without any slashes are matched against each of the pathname
+// components, otherwise the pattern is matched against the whole pathname
+// where a "**" component matches zero or more pathname components
+func matchGlob(pattern, pathname string) (matched bool) {
+	pattern = strings.Trim(pattern, "/")
+	names := strings.Split(pathname, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, name := range names {
+			if matched, _ = path.Match(pattern, name); matched {
+				return
+			}
+		}
+		return
+	}
+	return matchGlobParts(strings.Split(pattern, "/"), names)
+}
+
+func matchGlobParts(patterns, names []string) (matched bool) {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			// try consuming zero or more names
+			for idx := 0; idx <= len(names); idx++ {
+				if matchGlobParts(patterns[1:], names[idx:]) {
+					return true
+				}
+			}
+			return false
+		} else if len(names) == 0 {
+			return false
+		} else if matched, _ = path.Match(patterns[0], names[0]); !matched {
+			return false
+		}
+		patterns, names = patterns[1:], names[1:]
+	}
+	return len(names) == 0
+}
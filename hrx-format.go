@@ -0,0 +1,184 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-corelibs/hrx"
+	"github.com/go-corelibs/path"
+)
+
+const (
+	// FormatTable is the Options.Format for the human readable summary
+	// table, this is the default
+	FormatTable = "table"
+	// FormatJSON is the Options.Format for a JSON array of Record objects
+	FormatJSON = "json"
+	// FormatNDJSON is the Options.Format for newline delimited JSON Record
+	// objects
+	FormatNDJSON = "ndjson"
+	// FormatCSV is the Options.Format for comma separated Record values,
+	// with a heading line
+	FormatCSV = "csv"
+)
+
+// Record is the structured form of a single line of the summary table
+type Record struct {
+	// Pathname is the pathname reported
+	Pathname string `json:"pathname"`
+	// Type is either "file" or "dir", or "boundary" for the archive boundary
+	Type string `json:"type"`
+	// Size is the size in bytes, where applicable, or the boundary size for
+	// the archive boundary
+	Size int64 `json:"size"`
+	// Comment is the archive entry comment, if any
+	Comment string `json:"comment"`
+	// Operation is the operation performed, such as "extracted" or "kept"
+	Operation string `json:"operation"`
+	// Destination is the path on disk involved, where applicable
+	Destination string `json:"destination"`
+	// Reason is why the pathname was skipped, if it was
+	Reason string `json:"reason,omitempty"`
+}
+
+func validateFormat(opt *Options) (err error) {
+	switch opt.Format {
+	case "", FormatTable, FormatJSON, FormatNDJSON, FormatCSV:
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidFormat, opt.Format)
+	}
+	return
+}
+
+func isStructuredFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatNDJSON, FormatCSV:
+		return true
+	}
+	return false
+}
+
+// newRecord returns the Record for the report entry given
+func newRecord(a hrx.Archive, re *reportEntry) (r *Record) {
+	if re.isBoundary() {
+		size := re.argv[1].(int)
+		return &Record{Pathname: boundaryLine(size), Type: "boundary", Size: int64(size), Operation: re.note}
+	}
+	r = &Record{Pathname: re.pathname, Type: "file", Operation: re.note}
+	if size, ok := reportSize(re); ok {
+		r.Size = size
+	}
+	if strings.HasSuffix(re.pathname, "/") || re.note == hrx.OpCreated {
+		r.Type = "dir"
+	}
+	if entry := a.Entry(re.pathname); entry != nil {
+		r.Comment = entry.GetComment()
+		if entry.IsDir() {
+			r.Type = "dir"
+		}
+	}
+
+	switch re.note {
+	case hrx.OpExtracted, hrx.OpCreated, OpKept, OpNewer, OpDiffers, OpDirOnly:
+		if len(re.argv) > 0 {
+			r.Destination, _ = re.argv[0].(string)
+		}
+	case OpBackedUp:
+		r.Destination, _ = re.argv[0].(string)
+		r.Size = int64(re.argv[2].(int))
+	case OpWrote:
+		r.Destination = re.pathname
+		r.Size = path.FileSize(re.pathname)
+	case hrx.OpSkipped:
+		if s, ok := re.argv[0].(*Skipped); ok {
+			r.Destination = s.Source
+			r.Reason = s.Err.Error()
+		}
+	}
+
+	if re.note == OpKept || re.note == OpNewer {
+		// the existing file was left as-is
+		r.Size = path.FileSize(r.Destination)
+	}
+	return
+}
+
+// printRecords writes all the reported entries as Record instances to the
+// Options.Output, in the Options.Format given
+func (s *session) printRecords(a hrx.Archive) {
+	w := s.opt.Output
+	if w == nil {
+		w = os.Stdout
+	}
+
+	var records []*Record
+	var boundary *reportEntry
+	s.Lock()
+	for _, entry := range s.entries {
+		if entry.note != hrx.OpBoundary {
+			records = append(records, newRecord(a, entry))
+		} else if entry.isBoundary() {
+			// the last one is the boundary used
+			boundary = entry
+		}
+	}
+	if boundary != nil {
+		records = append(records, newRecord(a, boundary))
+	}
+	s.Unlock()
+
+	if err := writeRecords(w, s.opt.Format, records); err != nil {
+		s.notifier.Error("error writing records: %v\n", err)
+	}
+}
+
+func writeRecords(w io.Writer, format string, records []*Record) (err error) {
+	switch format {
+
+	case FormatJSON:
+		if records == nil {
+			records = []*Record{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(records)
+
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, record := range records {
+			if err = enc.Encode(record); err != nil {
+				return
+			}
+		}
+
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"pathname", "type", "size", "comment", "operation", "destination", "reason"})
+		for _, r := range records {
+			_ = cw.Write([]string{r.Pathname, r.Type, strconv.FormatInt(r.Size, 10), r.Comment, r.Operation, r.Destination, r.Reason})
+		}
+		cw.Flush()
+		err = cw.Error()
+
+	}
+	return
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// gHrxIgnoreName is the name of the ignore files read by default
+	gHrxIgnoreName = ".hrxignore"
+	// gGitIgnoreName is the name of the ignore files read when the
+	// Options.GitIgnore setting is true
+	gGitIgnoreName = ".gitignore"
+)
+
+type ignoreRule struct {
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func parseIgnoreRule(base, line string) (rule *ignoreRule) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	rule = &ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		rule.anchored = true
+		line = strings.TrimLeft(line, "/")
+	} else if strings.Contains(line, "/") {
+		rule.anchored = true
+	}
+	if line == "" {
+		return nil
+	}
+	rule.pattern = line
+	return
+}
+
+// matches reports whether the given slash-separated pathname, relative to
+// the directory the ignore file was found in, is matched by this rule
+func (r *ignoreRule) matches(pathname string, isDir bool) (matched bool) {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(pathname, r.base+"/") {
+			return false
+		}
+		pathname = pathname[len(r.base)+1:]
+	}
+	if r.anchored {
+		return matchGlobParts(strings.Split(r.pattern, "/"), strings.Split(pathname, "/"))
+	}
+	matched, _ = path.Match(r.pattern, path.Base(pathname))
+	return
+}
+
+// ignorer applies the rules of the ignore files found within a directory
+// tree, using gitignore semantics
+type ignorer struct {
+	src   source
+	root  string
+	names []string
+	rules map[string][]*ignoreRule
+}
+
+// newIgnorer returns an ignorer for the `root` directory given, or nil if
+// the Options given disable all ignore files
+func newIgnorer(src source, opt *Options, root string) (ig *ignorer) {
+	var names []string
+	if opt.GitIgnore {
+		names = append(names, gGitIgnoreName)
+	}
+	if !opt.NoIgnore {
+		// .hrxignore rules are loaded last so that they take precedence
+		names = append(names, gHrxIgnoreName)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return &ignorer{
+		src:   src,
+		root:  root,
+		names: names,
+		rules: make(map[string][]*ignoreRule),
+	}
+}
+
+func (ig *ignorer) load(dir string) (rules []*ignoreRule) {
+	var present bool
+	if rules, present = ig.rules[dir]; present {
+		return
+	}
+	for _, name := range ig.names {
+		data, err := ig.src.readFile(filepath.Join(ig.root, filepath.FromSlash(dir), name))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if rule := parseIgnoreRule(dir, line); rule != nil {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	ig.rules[dir] = rules
+	return
+}
+
+// match reports whether the pathname is ignored by the rules found within
+// the directories leading up to it, the last matching rule wins
+func (ig *ignorer) match(pathname string, isDir bool) (ignored bool) {
+	parts := strings.Split(pathname, "/")
+	for idx := range parts {
+		dir := strings.Join(parts[:idx], "/")
+		for _, rule := range ig.load(dir) {
+			if rule.matches(pathname, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return
+}
+
+// ignored reports whether the slash-separated pathname, relative to the
+// root directory, is ignored. When one of the parent directories is
+// ignored, that directory is returned as the `because` pathname with a
+// trailing slash, otherwise `because` is the pathname itself
+func (ig *ignorer) ignored(pathname string) (because string, ignored bool) {
+	parts := strings.Split(pathname, "/")
+	for idx := 1; idx < len(parts); idx++ {
+		if dir := strings.Join(parts[:idx], "/"); ig.match(dir, true) {
+			return dir + "/", true
+		}
+	}
+	if ig.match(pathname, false) {
+		return pathname, true
+	}
+	return
+}
+
+// filterIgnored returns the `files` found within the `root` directory that
+// are not ignored, along with the list of ignored pathnames. Ignored
+// directories are listed once, with a trailing slash
+func filterIgnored(src source, opt *Options, root string, files []string) (kept, skipped []string) {
+	ig := newIgnorer(src, opt, root)
+	if ig == nil {
+		return files, nil
+	}
+	seen := make(map[string]struct{})
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			kept = append(kept, file)
+			continue
+		}
+		if because, ignored := ig.ignored(filepath.ToSlash(rel)); ignored {
+			if _, present := seen[because]; !present {
+				seen[because] = struct{}{}
+				skipped = append(skipped, filepath.Join(root, filepath.FromSlash(because)))
+				if strings.HasSuffix(because, "/") {
+					skipped[len(skipped)-1] += "/"
+				}
+			}
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return
+}
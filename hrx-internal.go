@@ -17,15 +17,22 @@ package hrx
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/go-corelibs/hrx"
 	"github.com/go-corelibs/notify"
 	"github.com/go-corelibs/path"
+	"github.com/go-corelibs/tdata"
 )
 
+// gStdioName is the archive name used when reading from an io.Reader or
+// writing to an io.Writer
+const gStdioName = "-"
+
 var (
 	// Notifier is the user notice output handler
 	Notifier notify.Notifier
@@ -69,13 +76,557 @@ func prepareExistingSrc(src string) (a hrx.Archive, err error) {
 	return
 }
 
-func readFileAndSet(a hrx.Archive, src, name string) (err error) {
-	if err = validateExistingFile(src); err == nil {
-		var data []byte
-		if data, err = os.ReadFile(src); err == nil {
-			err = a.Set(name, string(data), "")
+func prepareReaderSrc(r io.Reader) (a hrx.Archive, err error) {
+	return hrx.ParseReader(gStdioName, r)
+}
+
+func validateOptions(opt *Options) (err error) {
+	if err = validatePatterns(opt); err == nil {
+		if err = validateBinary(opt); err == nil {
+			if err = validateOverwrite(opt); err == nil {
+				if err = validateFormat(opt); err == nil {
+					if err = validateSort(opt); err == nil {
+						err = validateComments(opt)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func selectPathnames(a hrx.Archive, opt *Options, pathnames ...string) (selected []string) {
+	tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
+	for _, pathname := range a.List() {
+		if !tc.NotPresent(pathname) && isSelected(opt, pathname) {
+			selected = append(selected, pathname)
+		}
+	}
+	return
+}
+
+func readFileAndSet(s *session, a hrx.Archive, src, name string, changedOnly bool) (err error) {
+	return readFileContents(s, src, name).set(s, a, name, changedOnly)
+}
+
+// fileContents is a file read to be added to an archive
+type fileContents struct {
+	contents string
+	encoded  bool
+	err      error
+}
+
+// readFileContents reads the `src` file to be added as the `name` entry,
+// applying the Options.MaxSize and Options.Binary settings. With BinaryBase64,
+// text files with lines that look like a boundary are encoded as well, unless
+// the entry is a nested archive
+func readFileContents(s *session, src, name string) (fc *fileContents) {
+	fc = &fileContents{}
+	if fc.err = s.src.validateFile(src); fc.err != nil {
+		return
+	} else if s.opt.MaxSize > 0 && s.src.fileSize(src) > s.opt.MaxSize {
+		fc.err = fmt.Errorf("%w: %q", ErrTooLarge, src)
+		return
+	}
+	var data []byte
+	if data, fc.err = s.src.readFile(src); fc.err != nil {
+		return
+	}
+	fc.contents = string(data)
+	if fc.encoded = !utf8.Valid(data); fc.encoded {
+		switch s.opt.Binary {
+		case BinaryError:
+			fc.err = fmt.Errorf("%w: %q", ErrNotPlainText, src)
+		case BinaryBase64:
+			fc.contents = encodeBase64Body(data)
+		default:
+			fc.encoded = false // let hrx.Archive.Set fail
+		}
+	} else if s.opt.Binary == BinaryBase64 && !strings.HasSuffix(name, ".hrx") && hasBoundaryLine(fc.contents) {
+		// only kept as-is when encoded, see boundaryScan.addEntry
+		fc.contents, fc.encoded = encodeBase64Body(data), true
+	}
+	return
+}
+
+// set adds the file contents to the archive as the `name` entry, unless
+// `changedOnly` is true and the entry is present and unchanged. Any
+// Options.EntryComments matching the `name` replace the entry comment
+func (fc *fileContents) set(s *session, a hrx.Archive, name string, changedOnly bool) (err error) {
+	if err = fc.err; err != nil {
+		return
+	}
+	body, comment, present := a.Get(name)
+	text, commented := entryComment(s.opt, name)
+	if present && changedOnly && body == fc.contents && isBase64Comment(comment) == fc.encoded &&
+		(!commented || sameComment(setBase64Comment(comment, false), text)) {
+		// nothing to update
+		return
+	}
+	if commented {
+		comment = text
+	}
+	if fc.encoded || isBase64Comment(comment) {
+		comment = setBase64Comment(comment, fc.encoded)
+	}
+	return setEntry(a, s.report, name, fc.contents, comment)
+}
+
+// setEntry is like hrx.Archive.Set except that new nested archives are kept
+// as-is, the same way CreateStream writes them. hrx.Archive.Set rewrites a
+// new nested archive with the next boundary size, which fails for nested
+// archives with any files and drops the entry, so a new nested archive is
+// set empty first and its body updated after, with `report` restored as the
+// archive reporter and given a single hrx.OpAppended report
+func setEntry(a hrx.Archive, report hrx.ReporterFn, pathname, body, comment string) (err error) {
+	if !strings.HasSuffix(pathname, ".hrx") || body == "" || a.Entry(pathname) != nil {
+		return a.Set(pathname, body, comment)
+	}
+	a.SetReporter(nil)
+	defer a.SetReporter(report)
+	if err = a.Set(pathname, "", comment); err != nil {
+		return
+	} else if err = a.Set(pathname, body, comment); err != nil {
+		return
+	} else if report != nil {
+		report(a.FileName(), pathname, hrx.OpAppended, body, comment)
+	}
+	return
+}
+
+func setEmptyDir(s *session, a hrx.Archive, name string, changedOnly bool) {
+	_, comment, present := a.Get(name)
+	text, commented := entryComment(s.opt, name)
+	if present && changedOnly && (!commented || sameComment(comment, text)) {
+		return
+	} else if !commented {
+		text = ""
+	}
+	_ = a.Set(name, "", text)
+}
+
+// candidate is a file to be added to an archive, or an empty directory to
+// be kept when the file is empty
+type candidate struct {
+	file, name string
+}
+
+// planned is a candidate with the pathname argument it was found with, for
+// when the candidates are all found before any are added
+type planned struct {
+	arg    string
+	walked bool
+	*candidate
+}
+
+// visitFn is called by walkPathnames with the selected candidates of each
+// pathname argument, `walked` is true when the candidates were found within
+// the `arg` directory
+type visitFn func(arg string, found []*candidate, walked bool) (skipped []*Skipped, err error)
+
+// walkPathnames finds the files to be added to an archive for each of the
+// `pathnames`, calling `visit` with the selected candidates in order. Files
+// ignored while walking directories are reported here. With an Options.Sort
+// order, all the candidates are found and sorted before any are visited
+func walkPathnames(s *session, visit visitFn, pathnames ...string) (skipped []*Skipped, err error) {
+	if !isSorting(s.opt.Sort) {
+		return walkArguments(s, visit, pathnames...)
+	}
+
+	var plan []*planned
+	if skipped, err = walkArguments(s, func(arg string, found []*candidate, walked bool) ([]*Skipped, error) {
+		for _, c := range found {
+			plan = append(plan, &planned{arg: arg, walked: walked, candidate: c})
+		}
+		return nil, nil
+	}, pathnames...); err != nil {
+		return
+	}
+	sortPlanned(s, plan)
+
+	for start, end := 0, 0; start < len(plan); start = end {
+		// consecutive candidates walked from the same argument are visited
+		// together, everything else is visited one at a time
+		first := plan[start]
+		for end = start + 1; first.walked && end < len(plan); end++ {
+			if next := plan[end]; !next.walked || next.arg != first.arg {
+				break
+			}
+		}
+		found := make([]*candidate, 0, end-start)
+		for _, p := range plan[start:end] {
+			found = append(found, p.candidate)
+		}
+		var visited []*Skipped
+		if visited, err = visit(first.arg, found, first.walked); err != nil {
+			return
+		}
+		skipped = append(skipped, visited...)
+	}
+	return
+}
+
+// walkArguments is walkPathnames, visiting the candidates of each of the
+// `pathnames` in the order found
+func walkArguments(s *session, visit visitFn, pathnames ...string) (skipped []*Skipped, err error) {
+	opt := s.opt
+	for _, arg := range pathnames {
+
+		if err = s.checkContext(arg); err != nil {
+			return
+		}
+
+		var visited []*Skipped
+		if s.src.isFile(arg) {
+			if name := preparePath(opt, arg); !isSelected(opt, name) {
+				if visited, err = skipUnselected(s, arg, &candidate{file: arg, name: name}); err != nil {
+					return
+				}
+			} else if visited, err = visit(arg, []*candidate{{file: arg, name: name}}, false); err != nil {
+				return
+			}
+			skipped = append(skipped, visited...)
+			continue
+		}
+
+		// is a directory
+		var files, ignored []string
+		if files, err = s.src.listFiles(arg, opt.Recurse, opt.All); err != nil {
+			return
+		}
+		files, ignored = filterIgnored(s.src, opt, arg, files)
+		for _, file := range ignored {
+			name := preparePath(opt, strings.TrimSuffix(file, "/"))
+			if strings.HasSuffix(file, "/") {
+				name += "/"
+			}
+			skip := newSkipped(name, file, ErrIgnored)
+			if err = skipFile(s, arg, skip); err != nil {
+				return
+			}
+			skipped = append(skipped, skip)
+		}
+
+		var found []*candidate
+		if len(files) == 0 && len(ignored) == 0 && opt.KeepEmpty {
+			// no files found, empty directory or not recursive
+			name := preparePath(opt, arg) + "/"
+			if isSelected(opt, name) {
+				found = append(found, &candidate{name: name})
+			} else if visited, err = skipUnselected(s, arg, &candidate{file: arg, name: name}); err != nil {
+				return
+			} else {
+				skipped = append(skipped, visited...)
+			}
+		}
+		for _, file := range files {
+			c := &candidate{file: file, name: preparePath(opt, file)}
+			if isSelected(opt, c.name) {
+				found = append(found, c)
+			} else if visited, err = skipUnselected(s, arg, c); err != nil {
+				return
+			} else {
+				skipped = append(skipped, visited...)
+			}
+		}
+		if len(found) > 0 {
+			if visited, err = visit(arg, found, len(files) > 0); err != nil {
+				return
+			}
+			skipped = append(skipped, visited...)
+		}
+
+	}
+	return
+}
+
+// skipUnselected reports the candidate left out by the Options.Include and
+// Options.Exclude patterns as skipped
+func skipUnselected(s *session, arg string, c *candidate) (skipped []*Skipped, err error) {
+	skip := newSkipped(c.name, c.file, ErrNotSelected)
+	if err = skipFile(s, arg, skip); err == nil {
+		skipped = append(skipped, skip)
+	}
+	return
+}
+
+// skipWalked reports the `err` for a file found while walking a directory
+// as skipped, when it is one of the errors that are skipped
+func skipWalked(s *session, arg string, c *candidate, err error) (skip *Skipped, ee error) {
+	if !isCreateFileErrIgnored(err) {
+		return nil, err
+	}
+	skip = newSkipped(c.name, c.file, err)
+	if ee = skipFile(s, arg, skip); ee != nil {
+		return nil, ee
+	}
+	return
+}
+
+func setPathnames(s *session, a hrx.Archive, changedOnly bool, pathnames ...string) (skipped []*Skipped, err error) {
+	return walkPathnames(s, func(arg string, found []*candidate, walked bool) (skipped []*Skipped, err error) {
+		if !walked {
+			if c := found[0]; c.file == "" {
+				setEmptyDir(s, a, c.name, changedOnly)
+			} else {
+				err = readFileAndSet(s, a, c.file, c.name, changedOnly)
+			}
+			return
+		}
+
+		read := readFilesConcurrently(s, found)
+		for idx, c := range found {
+			if err = s.checkContext(c.file); err != nil {
+				return
+			}
+			fc := read[idx]
+			if fc == nil {
+				fc = readFileContents(s, c.file, c.name)
+			}
+			if err = fc.set(s, a, c.name, changedOnly); err != nil {
+				var skip *Skipped
+				if skip, err = skipWalked(s, arg, c, err); err != nil {
+					return
+				}
+				skipped = append(skipped, skip)
+			}
+		}
+		return
+	}, pathnames...)
+}
+
+func listEntries(s *session, a hrx.Archive, src string, pathnames ...string) (err error) {
+	opt := s.opt
+	if err = validateOptions(opt); err != nil {
+		return
+	}
+	tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
+	for _, entry := range sortEntries(opt.Sort, a.Entries()) {
+		pathname := entry.GetPathname()
+		if tc.NotPresent(pathname) || !isSelected(opt, pathname) {
+			continue
+		} else if err = s.checkContext(pathname); err != nil {
+			return
+		}
+		s.report(src, pathname, OpListing, entry.GetBody())
+	}
+	s.printSummary(a, OpListing, src)
+	return
+}
+
+func createEntries(s *session, a hrx.Archive, pathnames ...string) (skipped []*Skipped, err error) {
+	if err = validateOptions(s.opt); err != nil {
+		return
+	}
+	var comment string
+	var commented bool
+	if comment, commented, err = archiveComment(s.opt); err != nil {
+		return
+	}
+	if s.opt.Boundary != BoundaryAuto {
+		a.SetReporter(s.report)
+		_ = a.SetBoundary(s.opt.Boundary)
+		if skipped, err = setPathnames(s, a, false, pathnames...); err != nil {
+			return
+		} else if commented {
+			a.SetComment(comment)
+		}
+		_, err = settleBoundary(s, a)
+		return
+	}
+
+	// the boundary depends on the contents and hrx.Archive.SetBoundary
+	// rewrites any nested archives already present, so the contents are
+	// collected in a scratch archive and copied once the boundary is set
+	scratch := hrx.New(a.FileName(), "")
+	scratch.SetReporter(s.report)
+	if skipped, err = setPathnames(s, scratch, false, pathnames...); err != nil {
+		return
+	}
+	if commented {
+		scratch.SetComment(comment)
+		a.SetComment(comment)
+	}
+	scan := make(boundaryScan)
+	if err = scan.addArchive(scratch); err != nil {
+		return
+	}
+	a.SetReporter(s.report)
+	_ = a.SetBoundary(scan.pick())
+	if err = copyEntries(a, scratch); err == nil {
+		_, err = settleBoundary(s, a)
+	}
+	a.SetReporter(s.report)
+	return
+}
+
+func extractEntries(s *session, a hrx.Archive, src, dst string, pathnames ...string) (err error) {
+	opt := s.opt
+	if err = validateOptions(opt); err != nil {
+		return
+	}
+	a.SetReporter(s.report)
+
+	// note if dst is created, so that it can be removed when cancelled
+	existed := path.Exists(dst)
+	if !opt.DryRun {
+		if err = path.MkdirAll(dst); err != nil {
+			return
+		}
+	}
+
+	var projected uint64
+	summarize := func() {
+		if opt.DryRun {
+			s.printSummarySize(a, hrx.OpExtracted, dst, projected)
+		} else {
+			s.printSummary(a, hrx.OpExtracted, dst)
+		}
+	}
+
+	filtered := len(opt.Include) > 0 || len(opt.Exclude) > 0
+	if filtered {
+		if pathnames = selectPathnames(a, opt, pathnames...); len(pathnames) == 0 {
+			// nothing selected, nothing to extract
+			summarize()
+			return
+		}
+	}
+
+	tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
+	pruning := opt.PruneDir || opt.TrimPrefix != ""
+
+	// check every destination before anything is written
+	destinations := make(map[string]string)
+	for _, entry := range a.Entries() {
+		pathname := entry.GetPathname()
+		if entry.IsComment() || tc.NotPresent(pathname) {
+			continue
+		}
+		name := pathname
+		if pruning {
+			name = pruneName(pathname, opt.TrimPrefix, opt.PruneDir)
+		}
+		if destinations[pathname], err = safeDestination(opt, dst, pathname, name, entry.IsDir()); err != nil {
+			return
+		}
+	}
+
+	var root *safeRoot
+	if root, err = newSafeRoot(opt, dst); err != nil {
+		return
+	} else if !existed && !opt.DryRun {
+		root.created = append(root.created, root.root)
+	}
+	modTime := archiveModTime(src)
+
+	for _, entry := range a.Entries() {
+		pathname := entry.GetPathname()
+		if entry.IsComment() {
+			continue
+		} else if tc.NotPresent(pathname) {
+			if !pruning {
+				s.report(src, pathname, hrx.OpSkipped, entry)
+			}
+			continue
+		} else if err = s.checkContext(pathname); err != nil {
+			root.undo()
+			return
+		}
+
+		destination := destinations[pathname]
+		reported := pathname
+		if pruning {
+			reported = destination
+		}
+		if entry.IsDir() {
+			if err = root.mkdirAll(pathname, destination); err != nil {
+				err = fmt.Errorf("error extracting %q: %w", pathname, err)
+				return
+			}
+			s.report(src, reported, hrx.OpCreated, destination)
+		} else if entry.IsFile() {
+			var data []byte
+			if data, err = decodeEntryBody(entry.GetBody(), entry.GetComment()); err != nil {
+				err = fmt.Errorf("%w: %q", err, pathname)
+				return
+			}
+
+			// check the parent directories before anything existing is
+			// looked at, kept or renamed
+			if err = root.mkdirAll(pathname, filepath.Dir(destination)); err != nil {
+				err = fmt.Errorf("error extracting %q: %w", pathname, err)
+				return
+			}
+
+			var note, backup string
+			if note, backup, err = applyOverwrite(opt, destination, modTime); err != nil {
+				err = fmt.Errorf("error extracting %q: %w", pathname, err)
+				return
+			} else if note == OpKept || note == OpNewer {
+				s.report(src, reported, note, destination)
+				continue
+			}
+
+			mode := hrx.DefaultFileMode
+			if pruning {
+				mode = 0660
+			}
+			if err = root.writeFile(pathname, destination, data, mode); err != nil {
+				if note == OpBackedUp && !opt.DryRun {
+					// put the original back
+					_ = os.Rename(backup, destination)
+				}
+				err = fmt.Errorf("error extracting %q: %w", pathname, err)
+				return
+			}
+			projected += uint64(len(data))
+			if note == OpBackedUp {
+				root.backups = append(root.backups, [2]string{backup, destination})
+				s.report(src, reported, OpBackedUp, destination, backup, len(data))
+			} else if pruning {
+				s.report(src, destination, OpWrote)
+			} else if opt.DryRun {
+				s.report(src, pathname, hrx.OpExtracted, destination, len(data))
+			} else {
+				s.report(src, pathname, hrx.OpExtracted, destination)
+			}
+		}
+	}
+
+	summarize()
+	return
+}
+
+// writeCreated writes the newly created archive to the `dst` file and
+// prints the summary, or only prints the projected summary for a dry run
+func writeCreated(s *session, a hrx.Archive, dst string) (err error) {
+	if s.opt.DryRun {
+		s.printSummarySize(a, OpArchived, dst, archiveSize(a))
+	} else if err = a.WriteFile(dst); err == nil {
+		s.printSummary(a, OpArchived, dst)
+	}
+	return
+}
+
+func writeArchive(w io.Writer, a hrx.Archive) (err error) {
+	// this is the same output as hrx.Archive.WriteFile
+	entries := a.Entries()
+	comment, hasComment := a.GetComment()
+	last := len(entries) - 1
+	for idx, entry := range entries {
+		if _, err = io.WriteString(w, entry.String()); err != nil {
+			return
+		}
+		if (idx < last || hasComment) && entry.IsFile() {
+			if _, err = io.WriteString(w, "\n"); err != nil {
+				return
+			}
 		}
 	}
+	if hasComment {
+		_, err = io.WriteString(w, boundaryLine(a.GetBoundary())+"\n"+comment)
+	}
 	return
 }
 
@@ -115,14 +666,19 @@ func pruneTopDir(input string) string {
 	return input
 }
 
+// prepareOptions returns a copy of the Options given with the defaults
+// applied, leaving the caller's Options unchanged
 func prepareOptions(opt *Options) (prepared *Options) {
 	if opt == nil {
-		opt = &Options{Recurse: true}
+		prepared = &Options{Recurse: true}
+	} else {
+		clone := *opt
+		prepared = &clone
 	}
-	if opt.Boundary <= 0 {
-		opt.Boundary = hrx.DefaultBoundary
+	if prepared.Boundary < 0 {
+		prepared.Boundary = BoundaryAuto
 	}
-	return opt
+	return
 }
 
 func pruneName(name, trimPrefix string, pruneDir bool) (pruned string) {
@@ -151,5 +707,5 @@ func preparePath(opt *Options, arg string) (pathname string) {
 }
 
 func isCreateFileErrIgnored(err error) (ignored bool) {
-	return errors.Is(err, hrx.ErrInvalidUnicode) || errors.Is(err, ErrNotRegular)
+	return errors.Is(err, hrx.ErrInvalidUnicode) || errors.Is(err, ErrNotRegular) || errors.Is(err, ErrTooLarge)
 }
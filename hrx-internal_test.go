@@ -15,6 +15,11 @@
 package hrx
 
 import (
+	"context"
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -88,8 +93,9 @@ func TestInternals(t *testing.T) {
 
 	Convey("prepare options", t, func() {
 
-		So(prepareOptions(nil), ShouldEqual, &Options{Recurse: true, Boundary: hrx.DefaultBoundary})
+		So(prepareOptions(nil), ShouldEqual, &Options{Recurse: true, Boundary: BoundaryAuto})
 		So(prepareOptions(&Options{Boundary: 2}), ShouldEqual, &Options{Boundary: 2})
+		So(prepareOptions(&Options{Boundary: -1}), ShouldEqual, &Options{Boundary: BoundaryAuto})
 
 	})
 
@@ -102,4 +108,404 @@ func TestInternals(t *testing.T) {
 
 	})
 
+	Convey("verify reader", t, func() {
+
+		problems := verifyReader(strings.NewReader(""))
+		So(problems, ShouldHaveLength, 1)
+		So(problems[0].Err, ShouldEqual, hrx.ErrEmptyArchive)
+
+		problems = verifyReader(strings.NewReader("not an archive\n"))
+		So(problems, ShouldHaveLength, 1)
+		So(problems[0].Line, ShouldEqual, 1)
+		So(problems[0].Err, ShouldEqual, hrx.ErrBadArchiveHeader)
+
+		problems = verifyReader(strings.NewReader(td.F("directory.hrx")))
+		So(problems, ShouldHaveLength, 0)
+
+		problems = verifyReader(strings.NewReader("<===> a\nA\n<===> nested.hrx\n<====> inner\n<===> b/\n<===> ../c\n"))
+		So(problems, ShouldHaveLength, 1)
+		So(problems[0].Error(), ShouldEqual, "6: ../c: "+hrx.ErrContainsRelPath.Error())
+
+		problems = verifyReader(strings.NewReader(
+			"<===> a\nA\n<===> a\nB\n<===> d/\nx\n<===>\nc1\n<===>\nc2\n<===> a/x\n<==> oops\n",
+		))
+		So(problems, ShouldHaveLength, 5)
+		So(problems[0].Line, ShouldEqual, 3)
+		So(problems[0].Err, ShouldWrap, hrx.ErrDuplicatePath)
+		So(problems[1].Line, ShouldEqual, 6)
+		So(problems[1].Err, ShouldEqual, hrx.ErrDirectoryHasContents)
+		So(problems[2].Line, ShouldEqual, 9)
+		So(problems[2].Err, ShouldEqual, hrx.ErrSequentialComments)
+		So(problems[3].Line, ShouldEqual, 11)
+		So(problems[3].Pathname, ShouldEqual, "a")
+		So(problems[3].Err, ShouldEqual, hrx.ErrFileAsParentDir)
+		So(problems[4].Line, ShouldEqual, 12)
+		So(problems[4].Err, ShouldWrap, ErrInconsistentBoundary)
+
+		problems = verifyReader(strings.NewReader("<=====> a\n<===> looks like boundary\n<=======>\n<=====> b\nB\n"))
+		So(problems, ShouldHaveLength, 2)
+		So(problems[0].Error(), ShouldEqual, "2: a: "+ErrInconsistentBoundary.Error()+": found 3, expected 5")
+		So(problems[1].Line, ShouldEqual, 3)
+		So(problems[1].Err, ShouldWrap, ErrInconsistentBoundary)
+
+	})
+
+	Convey("match glob", t, func() {
+
+		So(matchGlob("*.css", "output.css"), ShouldBeTrue)
+		So(matchGlob("*.css", "path/to/output.css"), ShouldBeTrue)
+		So(matchGlob("*.css", "input.scss"), ShouldBeFalse)
+		So(matchGlob("path", "path/to/file"), ShouldBeTrue)
+		So(matchGlob("path/*", "path/to/file"), ShouldBeFalse)
+		So(matchGlob("path/**", "path/to/file"), ShouldBeTrue)
+		So(matchGlob("path/**", "path"), ShouldBeTrue)
+		So(matchGlob("**/*.css", "output.css"), ShouldBeTrue)
+		So(matchGlob("**/*.css", "a/b/c/output.css"), ShouldBeTrue)
+		So(matchGlob("a/**/c/*.css", "a/b/c/output.css"), ShouldBeTrue)
+		So(matchGlob("a/**/c/*.css", "a/c/output.css"), ShouldBeTrue)
+		So(matchGlob("a/**/c/*.css", "a/b/output.css"), ShouldBeFalse)
+		So(matchGlob("/a/*", "a/b"), ShouldBeTrue)
+
+	})
+
+	Convey("is selected", t, func() {
+
+		So(isSelected(&Options{}, "any/thing"), ShouldBeTrue)
+		So(isSelected(&Options{Include: []string{"*.css"}}, "output.css"), ShouldBeTrue)
+		So(isSelected(&Options{Include: []string{"*.css"}}, "input.scss"), ShouldBeFalse)
+		So(isSelected(&Options{Exclude: []string{"*.log"}}, "logs/one.log"), ShouldBeFalse)
+		So(isSelected(&Options{Exclude: []string{"logs"}}, "logs/"), ShouldBeFalse)
+		So(isSelected(&Options{Include: []string{"**/*.log"}, Exclude: []string{"old/**"}}, "old/one.log"), ShouldBeFalse)
+		So(isSelected(&Options{Include: []string{"**/*.log"}, Exclude: []string{"old/**"}}, "new/one.log"), ShouldBeTrue)
+
+		So(validatePatterns(&Options{Include: []string{"**/*.css"}, Exclude: []string{"a/[bc]/*"}}), ShouldBeNil)
+		So(validatePatterns(&Options{Exclude: []string{"a/[bc/*"}}), ShouldWrap, path.ErrBadPattern)
+
+	})
+
+	Convey("ignore rules", t, func() {
+
+		So(parseIgnoreRule("", ""), ShouldBeNil)
+		So(parseIgnoreRule("", "# comment"), ShouldBeNil)
+		So(parseIgnoreRule("", "/"), ShouldBeNil)
+		So(parseIgnoreRule("", "*.log"), ShouldEqual, &ignoreRule{pattern: "*.log"})
+		So(parseIgnoreRule("sub", "!keep.log  "), ShouldEqual, &ignoreRule{base: "sub", pattern: "keep.log", negate: true})
+		So(parseIgnoreRule("", `\!bang`), ShouldEqual, &ignoreRule{pattern: "!bang"})
+		So(parseIgnoreRule("", "/build/"), ShouldEqual, &ignoreRule{pattern: "build", dirOnly: true, anchored: true})
+		So(parseIgnoreRule("", "docs/*.md"), ShouldEqual, &ignoreRule{pattern: "docs/*.md", anchored: true})
+
+		So(parseIgnoreRule("", "*.log").matches("a/b/c.log", false), ShouldBeTrue)
+		So(parseIgnoreRule("sub", "*.log").matches("c.log", false), ShouldBeFalse)
+		So(parseIgnoreRule("sub", "*.log").matches("sub/c.log", false), ShouldBeTrue)
+		So(parseIgnoreRule("", "build/").matches("build", false), ShouldBeFalse)
+		So(parseIgnoreRule("", "build/").matches("a/build", true), ShouldBeTrue)
+		So(parseIgnoreRule("", "/build").matches("a/build", true), ShouldBeFalse)
+		So(parseIgnoreRule("", "/build").matches("build", true), ShouldBeTrue)
+		So(parseIgnoreRule("", "**/tmp/*.swp").matches("a/b/tmp/x.swp", false), ShouldBeTrue)
+
+		So(newIgnorer(gOsSource, &Options{NoIgnore: true}, "."), ShouldBeNil)
+		So(newIgnorer(gOsSource, &Options{}, ".").names, ShouldEqual, []string{gHrxIgnoreName})
+		So(newIgnorer(gOsSource, &Options{GitIgnore: true}, ".").names, ShouldEqual, []string{gGitIgnoreName, gHrxIgnoreName})
+
+		ig := newIgnorer(gOsSource, &Options{}, ".")
+		ig.rules[""] = []*ignoreRule{
+			parseIgnoreRule("", "*.log"),
+			parseIgnoreRule("", "!keep.log"),
+			parseIgnoreRule("", "out/"),
+		}
+		ig.rules["sub"] = []*ignoreRule{
+			parseIgnoreRule("sub", "keep.log"),
+		}
+		ig.rules["out"] = []*ignoreRule{
+			parseIgnoreRule("out", "!*"),
+		}
+
+		because, ignored := ig.ignored("one.log")
+		So(ignored, ShouldBeTrue)
+		So(because, ShouldEqual, "one.log")
+		_, ignored = ig.ignored("keep.log")
+		So(ignored, ShouldBeFalse)
+		_, ignored = ig.ignored("sub/keep.log")
+		So(ignored, ShouldBeTrue)
+		because, ignored = ig.ignored("out/file.txt")
+		So(ignored, ShouldBeTrue)
+		So(because, ShouldEqual, "out/")
+		_, ignored = ig.ignored("file.txt")
+		So(ignored, ShouldBeFalse)
+
+	})
+
+	Convey("binary encoding", t, func() {
+
+		So(validateBinary(&Options{}), ShouldBeNil)
+		So(validateBinary(&Options{Binary: BinaryBase64}), ShouldBeNil)
+		So(validateBinary(&Options{Binary: "hex"}), ShouldWrap, ErrInvalidBinaryMode)
+
+		So(isBase64Comment(""), ShouldBeFalse)
+		So(isBase64Comment("notes\n"+EncodingBase64), ShouldBeTrue)
+		So(setBase64Comment("", true), ShouldEqual, EncodingBase64)
+		So(setBase64Comment("notes", true), ShouldEqual, EncodingBase64+"\nnotes")
+		So(setBase64Comment(EncodingBase64+"\nnotes", true), ShouldEqual, EncodingBase64+"\nnotes")
+		So(setBase64Comment(EncodingBase64+"\nnotes", false), ShouldEqual, "notes")
+
+		data := make([]byte, 100)
+		for idx := range data {
+			data[idx] = byte(idx + 200)
+		}
+		body := encodeBase64Body(data)
+		lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+		So(lines, ShouldHaveLength, 2)
+		So(lines[0], ShouldHaveLength, gBase64LineWidth)
+		decoded, err := decodeEntryBody(body, EncodingBase64)
+		So(err, ShouldBeNil)
+		So(decoded, ShouldEqual, data)
+		decoded, err = decodeEntryBody(body, "")
+		So(err, ShouldBeNil)
+		So(string(decoded), ShouldEqual, body)
+		_, err = decodeEntryBody("not*base64\n", EncodingBase64)
+		So(err, ShouldWrap, ErrInvalidEncoding)
+
+	})
+
+	Convey("safe destination", t, func() {
+
+		opt := &Options{}
+		for _, name := range []string{"file", "dir/file", "a/b/c/", "..file", "dir/..hidden"} {
+			destination, err := safeDestination(opt, "dst", name, name, strings.HasSuffix(name, "/"))
+			So(err, ShouldBeNil)
+			So(destination, ShouldEqual, filepath.Join("dst", name))
+		}
+
+		destination, err := safeDestination(opt, "dst", "top/", "", true)
+		So(err, ShouldBeNil)
+		So(destination, ShouldEqual, "dst")
+
+		for _, name := range []string{"", ".", "/etc/passwd", "../file", "dir/../../file", "dir/.."} {
+			_, err = safeDestination(opt, "dst", "entry", name, false)
+			So(err, ShouldWrap, ErrUnsafePath)
+			var upe *UnsafePathError
+			So(errors.As(err, &upe), ShouldBeTrue)
+			So(upe.Pathname, ShouldEqual, "entry")
+		}
+
+		// names pruned from entry pathnames which the parser accepts
+		for _, check := range []struct {
+			pathname, trimPrefix string
+			pruneDir             bool
+			name                 string
+		}{
+			{"top/../../escaped.txt", "", true, "../../escaped.txt"},
+			{"top/sub/../../../escaped.txt", "top/", false, "sub/../../../escaped.txt"},
+			{"top//etc/passwd", "top", false, "/etc/passwd"},
+			{"top/file.txt", "top/file.txt", false, ""},
+			{"loose.txt", "", true, ""},
+		} {
+			name := pruneName(check.pathname, check.trimPrefix, check.pruneDir)
+			So(name, ShouldEqual, check.name)
+			_, err = safeDestination(opt, "dst", check.pathname, name, false)
+			So(err, ShouldWrap, ErrUnsafePath)
+			var upe *UnsafePathError
+			So(errors.As(err, &upe), ShouldBeTrue)
+			So(upe.Pathname, ShouldEqual, check.pathname)
+			So(upe.Destination, ShouldEqual, filepath.Join("dst", name))
+		}
+
+		destination, err = safeDestination(&Options{UnsafePaths: true}, "dst", "entry", "../file", false)
+		So(err, ShouldBeNil)
+		So(destination, ShouldEqual, "file")
+
+	})
+
+	Convey("overwrite policies", t, func() {
+
+		So(validateOverwrite(&Options{}), ShouldBeNil)
+		So(validateOverwrite(&Options{Overwrite: OverwriteBackup}), ShouldBeNil)
+		So(validateOverwrite(&Options{Overwrite: "never"}), ShouldWrap, ErrInvalidOverwrite)
+
+		So(archiveModTime(gStdioName).IsZero(), ShouldBeFalse)
+
+	})
+
+	Convey("structured records", t, func() {
+
+		So(validateFormat(&Options{}), ShouldBeNil)
+		So(validateFormat(&Options{Format: FormatCSV}), ShouldBeNil)
+		So(validateFormat(&Options{Format: "yaml"}), ShouldWrap, ErrInvalidFormat)
+		So(isStructuredFormat(FormatTable), ShouldBeFalse)
+		So(isStructuredFormat(FormatNDJSON), ShouldBeTrue)
+
+		var buf strings.Builder
+		So(writeRecords(&buf, FormatJSON, nil), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "[]\n")
+
+		a := hrx.New("test.hrx", "")
+		So(a.Set("dir/", "", ""), ShouldBeNil)
+		skip := &Skipped{Pathname: "big.bin", Source: "src/big.bin", Err: ErrTooLarge}
+		So(newRecord(a, &reportEntry{pathname: "big.bin", note: hrx.OpSkipped, argv: []interface{}{skip}}), ShouldEqual, &Record{
+			Pathname: "big.bin", Type: "file", Operation: hrx.OpSkipped, Destination: "src/big.bin", Reason: ErrTooLarge.Error(),
+		})
+		So(newRecord(a, &reportEntry{pathname: "file.txt", note: OpBackedUp, argv: []interface{}{"out/file.txt", "out/file.txt.~1~", 10}}), ShouldEqual, &Record{
+			Pathname: "file.txt", Type: "file", Size: 10, Operation: OpBackedUp, Destination: "out/file.txt",
+		})
+		So(newRecord(a, &reportEntry{pathname: "dir/", note: OpListing, argv: []interface{}{""}}).Type, ShouldEqual, "dir")
+
+	})
+
+	Convey("concurrent reads", t, func() {
+
+		var files []*candidate
+		for _, name := range []string{"input.scss", "output.css", "nope"} {
+			files = append(files, &candidate{file: "testdata/simple/" + name, name: name})
+		}
+		s := newSession(context.Background(), &Options{Workers: 1})
+		So(readFilesConcurrently(s, files), ShouldEqual, []*fileContents{nil, nil, nil})
+
+		s = newSession(context.Background(), &Options{Workers: 4})
+		read := readFilesConcurrently(s, files)
+		So(read, ShouldHaveLength, 3)
+		So(read[0].err, ShouldBeNil)
+		So(read[0].contents, ShouldStartWith, "ul {")
+		So(read[1].err, ShouldBeNil)
+		So(read[2].err, ShouldWrap, ErrFileNotFound)
+
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+		s = newSession(cancelled, &Options{Workers: 4})
+		So(readFilesConcurrently(s, files), ShouldEqual, []*fileContents{nil, nil, nil})
+
+	})
+
+	Convey("boundary selection", t, func() {
+
+		So(boundarySize(""), ShouldEqual, 0)
+		So(boundarySize("<>"), ShouldEqual, 0)
+		So(boundarySize("<==="), ShouldEqual, 0)
+		So(boundarySize("<==!==>"), ShouldEqual, 0)
+		So(boundarySize(" <===>"), ShouldEqual, 0)
+		So(boundarySize("<=>"), ShouldEqual, 1)
+		So(boundarySize("<=====> path"), ShouldEqual, 5)
+		So(boundarySize("<=====>x"), ShouldEqual, 5)
+		So(boundaryLine(3), ShouldEqual, "<===>")
+
+		scan := make(boundaryScan)
+		So(scan.pick(), ShouldEqual, hrx.DefaultBoundary)
+		scan.add("text <=====> text\n<===> small\n")
+		So(scan.pick(), ShouldEqual, hrx.DefaultBoundary)
+		scan.add("<=====> one\n<======>")
+		So(scan.pick(), ShouldEqual, 7)
+
+		So(hasBoundaryLine("text <=====> text\n"), ShouldBeFalse)
+		So(hasBoundaryLine("text\n<=> text\n"), ShouldBeTrue)
+
+		// only nested archives may have lines that look like a boundary
+		scan = make(boundaryScan)
+		So(scan.addEntry("nested.hrx", "<======> inner\n<=======> deeper\n", ""), ShouldBeNil)
+		So(scan.addEntry("dir/", "", ""), ShouldBeNil)
+		So(scan.addEntry("file.txt", "<===> looks like boundary\n", ""), ShouldWrap, ErrBoundaryCollision)
+		So(scan.addEntry("nested.hrx", "", "<===>"), ShouldWrap, ErrBoundaryCollision)
+		So(scan, ShouldHaveLength, 2)
+		So(scan.pick(), ShouldEqual, 5)
+
+		a := hrx.New("test.hrx", "")
+		So(a.Set("file.txt", "body\n", "plain"), ShouldBeNil)
+		scan = make(boundaryScan)
+		So(scan.addArchive(a), ShouldBeNil)
+		So(scan, ShouldHaveLength, 0)
+		a.SetComment("<========>")
+		err := scan.addArchive(a)
+		So(err, ShouldWrap, ErrBoundaryCollision)
+		So(err.Error(), ShouldContainSubstring, `"test.hrx"`)
+
+		re := &reportEntry{note: hrx.OpBoundary, argv: []interface{}{5, 7}}
+		So(re.isBoundary(), ShouldBeTrue)
+		So(re.displayName(), ShouldEqual, "<=======> (7)")
+		re.pathname = "nested.hrx"
+		So(re.isBoundary(), ShouldBeFalse)
+
+	})
+
+	Convey("sort orders", t, func() {
+
+		So(validateSort(&Options{}), ShouldBeNil)
+		So(validateSort(&Options{Sort: SortNone}), ShouldBeNil)
+		So(validateSort(&Options{Sort: "random"}), ShouldWrap, ErrInvalidSort)
+		So(isSorting(""), ShouldBeFalse)
+		So(isSorting(SortNone), ShouldBeFalse)
+		So(isSorting(SortMtime), ShouldBeTrue)
+
+		names := func(items []*sortItem) (found []string) {
+			for _, item := range items {
+				found = append(found, item.name)
+			}
+			return
+		}
+		items := []*sortItem{{index: 0, name: "b"}, {index: 1, name: "a"}, {index: 2, name: "a"}}
+		sortItems(SortSize, items)
+		So(names(items), ShouldEqual, []string{"a", "a", "b"})
+		So(items[0].index, ShouldEqual, 1)
+
+		items = []*sortItem{{name: "x01"}, {name: "x1"}, {name: "x2"}}
+		sortItems(SortNatural, items)
+		So(names(items)[2], ShouldEqual, "x2")
+
+		s := newSession(context.Background(), &Options{Sort: SortSize})
+		plan := []*planned{
+			{arg: "testdata/simple", walked: true, candidate: &candidate{file: "testdata/simple/input.scss", name: "input.scss"}},
+			{arg: "empty", candidate: &candidate{name: "empty/"}},
+			{arg: "testdata/simple", walked: true, candidate: &candidate{file: "testdata/simple/output.css", name: "output.css"}},
+		}
+		sortPlanned(s, plan)
+		So(plan[0].name, ShouldEqual, "empty/")
+		So(plan[1].name, ShouldEqual, "output.css")
+
+		a := hrx.New("test.hrx", "")
+		So(a.Set("b.txt", "b", ""), ShouldBeNil)
+		So(a.Set("a.txt", "aa", ""), ShouldBeNil)
+		So(sortEntries(SortNone, a.Entries())[0].GetPathname(), ShouldEqual, "b.txt")
+		So(sortEntries(SortName, a.Entries())[0].GetPathname(), ShouldEqual, "a.txt")
+		So(sortEntries(SortMtime, a.Entries())[0].GetPathname(), ShouldEqual, "a.txt")
+
+	})
+
+	Convey("comments", t, func() {
+
+		So(validateComments(&Options{}), ShouldBeNil)
+		So(validateComments(&Options{EntryComments: []string{"*.txt=", "docs/**=a=b"}}), ShouldBeNil)
+		So(validateComments(&Options{EntryComments: []string{"=text"}}), ShouldWrap, ErrInvalidComment)
+		So(validateComments(&Options{EntryComments: []string{"[=text"}}), ShouldWrap, path.ErrBadPattern)
+		So(validateComments(&Options{Comment: "one", CommentFile: "two"}), ShouldWrap, ErrInvalidComment)
+
+		opt := &Options{EntryComments: []string{"*.txt=text", "docs/**=docs=yes", "docs/old.txt="}}
+		comment, ok := entryComment(opt, "notes.txt")
+		So(ok, ShouldBeTrue)
+		So(comment, ShouldEqual, "text")
+		comment, ok = entryComment(opt, "docs/new.txt")
+		So(ok, ShouldBeTrue)
+		So(comment, ShouldEqual, "docs=yes")
+		comment, ok = entryComment(opt, "docs/old.txt")
+		So(ok, ShouldBeTrue)
+		So(comment, ShouldEqual, "")
+		comment, ok = entryComment(opt, "docs/")
+		So(ok, ShouldBeTrue)
+		_, ok = entryComment(opt, "image.bin")
+		So(ok, ShouldBeFalse)
+		So(sameComment("text\n", "text"), ShouldBeTrue)
+		So(sameComment("text", "other"), ShouldBeFalse)
+
+		comment, ok, err := archiveComment(&Options{})
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+		comment, ok, err = archiveComment(&Options{Comment: "given"})
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(comment, ShouldEqual, "given")
+		comment, ok, err = archiveComment(&Options{CommentFile: "testdata/simple/output.css"})
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(comment, ShouldStartWith, "ul {")
+		_, _, err = archiveComment(&Options{CommentFile: "testdata/nope"})
+		So(err, ShouldWrap, ErrFileNotFound)
+
+	})
+
 }
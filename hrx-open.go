@@ -0,0 +1,36 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// Open parses an existing `src` archive file, the same way as List, Extract
+// and all the other operations on existing archives
+func Open(src string) (a hrx.Archive, err error) {
+	return prepareExistingSrc(src)
+}
+
+// ReadEntry returns the contents of the file `entry` given, decoding any
+// base64 encoded body, the same way as Cat and Extract
+func ReadEntry(entry hrx.Entry) (data []byte, err error) {
+	if data, err = decodeEntryBody(entry.GetBody(), entry.GetComment()); err != nil {
+		err = fmt.Errorf("%w: %q", err, entry.GetPathname())
+	}
+	return
+}
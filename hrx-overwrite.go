@@ -0,0 +1,97 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// OverwriteAlways is the Options.Overwrite policy that replaces any
+	// existing files, this is the default
+	OverwriteAlways = "overwrite"
+	// OverwriteKeep is the Options.Overwrite policy that leaves any existing
+	// files unchanged
+	OverwriteKeep = "keep"
+	// OverwriteSkipNewer is the Options.Overwrite policy that leaves any
+	// existing files unchanged when they are newer than the archive
+	OverwriteSkipNewer = "skip-newer"
+	// OverwriteBackup is the Options.Overwrite policy that renames any
+	// existing files to a numbered backup before replacing them
+	OverwriteBackup = "backup"
+)
+
+func validateOverwrite(opt *Options) (err error) {
+	switch opt.Overwrite {
+	case "", OverwriteAlways, OverwriteKeep, OverwriteSkipNewer, OverwriteBackup:
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidOverwrite, opt.Overwrite)
+	}
+	return
+}
+
+// archiveModTime returns the modification time of the `src` archive file,
+// or the current time when the archive is not a file
+func archiveModTime(src string) (modTime time.Time) {
+	if src != gStdioName {
+		if info, err := os.Stat(src); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Now()
+}
+
+// applyOverwrite applies the Options.Overwrite policy to the existing
+// `destination` file, returning the reporting note to use instead of
+// writing the file, if any. When backing up, the `backup` path is returned
+func applyOverwrite(opt *Options, destination string, modTime time.Time) (note, backup string, err error) {
+	var info os.FileInfo
+	if info, err = os.Lstat(destination); os.IsNotExist(err) {
+		// nothing to overwrite
+		return "", "", nil
+	} else if err != nil || info.IsDir() {
+		// let writing the file fail
+		return "", "", nil
+	}
+
+	switch opt.Overwrite {
+	case OverwriteKeep:
+		note = OpKept
+	case OverwriteSkipNewer:
+		if info.ModTime().After(modTime) {
+			note = OpNewer
+		}
+	case OverwriteBackup:
+		backup, note = nextBackupName(destination), OpBackedUp
+		if !opt.DryRun {
+			err = os.Rename(destination, backup)
+		}
+	}
+	return
+}
+
+// nextBackupName returns the first numbered backup name, in the style of
+// "name.~1~", that does not exist yet
+func nextBackupName(destination string) (backup string) {
+	for idx := 1; ; idx++ {
+		backup = destination + ".~" + strconv.Itoa(idx) + "~"
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			return
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnsafePathError is returned when extracting an entry would write outside
+// of the destination directory
+type UnsafePathError struct {
+	// Pathname is the archive pathname of the offending entry
+	Pathname string
+	// Destination is the path the entry would have been written to
+	Destination string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrUnsafePath, e.Pathname)
+}
+
+func (e *UnsafePathError) Unwrap() error {
+	return ErrUnsafePath
+}
+
+// safeDestination returns the path within `dst` that the entry `pathname`
+// is to be written to, using the pruned `name` given. An UnsafePathError is
+// returned if the destination is not within `dst`, unless the
+// Options.UnsafePaths setting is true. Directory entries are allowed to
+// collapse onto `dst` itself
+func safeDestination(opt *Options, dst, pathname, name string, isDir bool) (destination string, err error) {
+	destination = filepath.Join(dst, name)
+	if opt.UnsafePaths {
+		return
+	}
+
+	unsafe := func() (string, error) {
+		return "", &UnsafePathError{Pathname: pathname, Destination: destination}
+	}
+
+	clean := strings.TrimSuffix(filepath.ToSlash(name), "/")
+	if clean == "" || clean == "." {
+		if isDir {
+			return
+		}
+		return unsafe()
+	} else if strings.HasPrefix(clean, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return unsafe()
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if part == ".." {
+			return unsafe()
+		}
+	}
+	if !isWithin(dst, destination) {
+		return unsafe()
+	}
+	return
+}
+
+// safeRoot writes files and directories within a destination root directory
+// without following any symlinks that lead outside of it
+type safeRoot struct {
+	opt  *Options
+	root string
+	real string
+	// created is the list of files and directories created, in order
+	created []string
+	// backups is the list of backup and destination pairs renamed
+	backups [][2]string
+}
+
+func newSafeRoot(opt *Options, dst string) (sr *safeRoot, err error) {
+	sr = &safeRoot{opt: opt}
+	if sr.root, err = filepath.Abs(dst); err != nil {
+		return nil, err
+	} else if sr.real, err = filepath.EvalSymlinks(sr.root); err != nil {
+		if !opt.DryRun || !os.IsNotExist(err) {
+			return nil, err
+		}
+		// nothing exists to be checked during a dry run
+		sr.real, err = sr.root, nil
+	}
+	return
+}
+
+// isWithin reports whether the `target` path is the `root` path or is
+// somewhere beneath it
+func isWithin(root, target string) (within bool) {
+	rel, err := filepath.Rel(root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkSymlink returns an UnsafePathError if the `link` given does not
+// resolve to a path within the root directory
+func (sr *safeRoot) checkSymlink(pathname, link string) (err error) {
+	var resolved string
+	if resolved, err = filepath.EvalSymlinks(link); err != nil || !isWithin(sr.real, resolved) {
+		return &UnsafePathError{Pathname: pathname, Destination: link}
+	}
+	return
+}
+
+// mkdirAll is like os.MkdirAll except that every existing component of
+// `dir` beneath the root directory is checked and any symlinks leading
+// outside of the root directory are refused
+func (sr *safeRoot) mkdirAll(pathname, dir string) (err error) {
+	if sr.opt.UnsafePaths {
+		if sr.opt.DryRun {
+			return nil
+		}
+		return os.MkdirAll(dir, 0770)
+	}
+
+	var abs, rel string
+	if abs, err = filepath.Abs(dir); err != nil {
+		return
+	} else if rel, err = filepath.Rel(sr.root, abs); err != nil {
+		return
+	} else if rel == "." {
+		return
+	}
+
+	current := sr.root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		var info os.FileInfo
+		if info, err = os.Lstat(current); os.IsNotExist(err) {
+			if sr.opt.DryRun {
+				// nothing further exists to be checked
+				return nil
+			} else if err = os.Mkdir(current, 0770); err != nil {
+				return
+			}
+			sr.created = append(sr.created, current)
+			continue
+		} else if err != nil {
+			return
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err = sr.checkSymlink(pathname, current); err != nil {
+				return
+			} else if info, err = os.Stat(current); err != nil {
+				return
+			}
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %q", ErrNotDirectory, current)
+		}
+	}
+	return
+}
+
+// writeFile is like os.WriteFile except that the parent directories are
+// created with mkdirAll and any symlink present at the `destination` is
+// replaced rather than followed
+func (sr *safeRoot) writeFile(pathname, destination string, data []byte, mode os.FileMode) (err error) {
+	if err = sr.mkdirAll(pathname, filepath.Dir(destination)); err != nil {
+		return
+	}
+	if sr.opt.DryRun {
+		return
+	}
+	info, statErr := os.Lstat(destination)
+	if !sr.opt.UnsafePaths && statErr == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err = os.Remove(destination); err != nil {
+			return
+		}
+	}
+	if err = os.WriteFile(destination, data, mode); err == nil && os.IsNotExist(statErr) {
+		sr.created = append(sr.created, destination)
+	}
+	return
+}
+
+// undo removes the files and directories created and then restores any
+// backups made, each in the reverse order, such as when an extraction is
+// cancelled
+func (sr *safeRoot) undo() {
+	for idx := len(sr.created) - 1; idx >= 0; idx-- {
+		_ = os.Remove(sr.created[idx])
+	}
+	for idx := len(sr.backups) - 1; idx >= 0; idx-- {
+		_ = os.Rename(sr.backups[idx][0], sr.backups[idx][1])
+	}
+}
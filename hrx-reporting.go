@@ -15,6 +15,7 @@
 package hrx
 
 import (
+	"context"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -23,69 +24,115 @@ import (
 	"github.com/dustin/go-humanize"
 
 	"github.com/go-corelibs/hrx"
+	"github.com/go-corelibs/notify"
 	"github.com/go-corelibs/path"
 )
 
-var (
-	gReporting *reporting
-)
-
 type reportEntry struct {
 	src, pathname, note string
 	argv                []interface{}
 }
 
-type reporting struct {
-	entries []*reportEntry
-	sync.RWMutex
+// displayName returns the pathname to show in the summary table, which
+// includes the reason for any Skipped files and is the boundary itself for
+// the archive boundary
+func (re *reportEntry) displayName() (name string) {
+	if re.note == hrx.OpSkipped && len(re.argv) > 0 {
+		if s, ok := re.argv[0].(*Skipped); ok {
+			return re.pathname + " (" + s.Err.Error() + ")"
+		}
+	} else if re.isBoundary() {
+		size := re.argv[1].(int)
+		return boundaryLine(size) + " (" + strconv.Itoa(size) + ")"
+	}
+	return re.pathname
+}
+
+// isBoundary reports whether this is the hrx.OpBoundary report of the
+// top-level archive, as opposed to any nested archives
+func (re *reportEntry) isBoundary() (ok bool) {
+	return re.note == hrx.OpBoundary && re.pathname == "" && len(re.argv) > 1
 }
 
-func reporterFn(src, pathname, note string, argv ...interface{}) {
-	safeInitReporting()
-	gReporting.Lock()
-	defer gReporting.Unlock()
-	gReporting.entries = append(gReporting.entries, &reportEntry{src: src, pathname: pathname, note: note, argv: argv})
+// session is the state of a single List, Create, Extract or other
+// operation, collecting the reported entries and rendering the summary with
+// the Notifier of the Options given, so that concurrent operations do not
+// interfere with each other
+type session struct {
+	ctx      context.Context
+	opt      *Options
+	src      source
+	notifier notify.Notifier
+	entries  []*reportEntry
+	sync.RWMutex
 }
 
-func safeInitReporting() {
-	if gReporting == nil {
-		gReporting = &reporting{}
+// newSession returns a new session for the context with a prepared copy of
+// the Options given, using the package Notifier when Options.Notifier is nil
+func newSession(ctx context.Context, opt *Options) (s *session) {
+	s = &session{ctx: ctx, opt: prepareOptions(opt), src: gOsSource, notifier: Notifier}
+	if s.opt.Notifier != nil {
+		s.notifier = s.opt.Notifier
 	}
+	return
 }
 
-func safeResetReporting() {
-	safeInitReporting()
-	gReporting.Lock()
-	defer gReporting.Unlock()
-	gReporting.entries = make([]*reportEntry, 0)
+// report is the hrx.ReporterFn for the session
+func (s *session) report(src, pathname, note string, argv ...interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = append(s.entries, &reportEntry{src: src, pathname: pathname, note: note, argv: argv})
 }
 
-func printSummary(a hrx.Archive, note, value string) {
-	maxPathname, maxComment, _ := printSummaryReporting(a, note)
-	if path.IsDir(value) {
-		note = humanize.Bytes(path.DirSize(value))
+func (s *session) printSummary(a hrx.Archive, note, value string) {
+	var size uint64
+	if value == gStdioName {
+		size = archiveSize(a)
+	} else if path.IsDir(value) {
+		size = path.DirSize(value)
 	} else {
-		note = humanize.Bytes(uint64(path.FileSize(value)))
+		size = uint64(path.FileSize(value))
+	}
+	s.printSummarySize(a, note, value, size)
+}
+
+// printSummarySize is like printSummary except that the total size is given
+// instead of measured, such as the projected sizes of a dry run
+func (s *session) printSummarySize(a hrx.Archive, note, value string, size uint64) {
+	if isStructuredFormat(s.opt.Format) {
+		s.printRecords(a)
+		return
 	}
+	maxPathname, maxComment, _ := s.printSummaryReporting(a, note)
+	note = humanize.Bytes(size)
 	if comment, ok := a.GetComment(); ok || maxComment > 0 {
 		if comment != "" {
 			comment = strings.ReplaceAll(strings.TrimSpace(comment), "\n", "\\n")
 		} else {
 			comment = "-"
 		}
-		Notifier.Info("%11s | %-"+strconv.Itoa(maxPathname)+"s | %s\n", note, filepath.Base(value), comment)
+		s.notifier.Info("%11s | %-"+strconv.Itoa(maxPathname)+"s | %s\n", note, filepath.Base(value), comment)
 		return
 	}
-	Notifier.Info("%11s | %s\n", note, filepath.Base(value))
+	s.notifier.Info("%11s | %s\n", note, filepath.Base(value))
+}
+
+// archiveSize returns the size of the archive as written by writeArchive
+func archiveSize(a hrx.Archive) (size uint64) {
+	var buf strings.Builder
+	_ = writeArchive(&buf, a)
+	return uint64(buf.Len())
 }
 
-func printSummaryReporting(a hrx.Archive, note string) (maxPathname, maxComment int, ok bool) {
-	if ok = gReporting != nil; ok {
-		gReporting.Lock()
-		defer gReporting.Unlock()
+func (s *session) printSummaryReporting(a hrx.Archive, note string) (maxPathname, maxComment int, ok bool) {
+	if ok = s != nil; ok {
+		s.Lock()
+		defer s.Unlock()
 
-		for _, entry := range gReporting.entries {
-			if size := len(entry.pathname); size > maxPathname {
+		for _, entry := range s.entries {
+			if entry.note == hrx.OpBoundary && !entry.isBoundary() {
+				continue
+			} else if size := len(entry.displayName()); size > maxPathname {
 				maxPathname = size
 			}
 			if _, comment, present := a.Get(entry.pathname); present {
@@ -105,51 +152,78 @@ func printSummaryReporting(a hrx.Archive, note string) (maxPathname, maxComment
 		}
 		format := strings.Join(headingf, " | ") + "\n"
 
-		Notifier.Info(format, headingv...)
-		Notifier.Info(separator + "--\n")
+		s.notifier.Info(format, headingv...)
+		s.notifier.Info(separator + "--\n")
 
 		var comment string
-		for _, entry := range gReporting.entries {
-			if _, comment, _ = a.Get(entry.pathname); comment != "" {
+		var boundary *reportEntry
+		for _, entry := range s.entries {
+			if entry.note == hrx.OpBoundary {
+				if entry.isBoundary() {
+					// the last one is the boundary used
+					boundary = entry
+				}
+				continue
+			} else if _, comment, _ = a.Get(entry.pathname); comment != "" {
 				comment = strings.ReplaceAll(strings.TrimSpace(comment), "\n", "\\n")
 			} else if maxComment > 0 {
 				comment = "-"
 			} else {
 				comment = ""
 			}
-			printSummaryReport(entry, format, comment)
+			s.printSummaryReport(entry, format, comment)
+		}
+		if boundary != nil {
+			if comment = ""; maxComment > 0 {
+				comment = "-"
+			}
+			s.printSummaryReport(boundary, format, comment)
 		}
 
-		Notifier.Info(separator + "--\n")
+		s.notifier.Info(separator + "--\n")
 	}
 	return
 }
 
-func printSummaryReport(re *reportEntry, format, comment string) {
-	var desc string
-	switch re.note {
-	case hrx.OpBoundary:
+func (s *session) printSummaryReport(re *reportEntry, format, comment string) {
+	desc := re.note
+	if size, ok := reportSize(re); ok {
+		desc = humanize.Bytes(uint64(size))
+	}
+	if comment != "" {
+		s.notifier.Info(format, desc, re.displayName(), comment)
 		return
-	case OpListing:
-		desc = humanize.Bytes(uint64(len(re.argv[0].(string))))
+	}
+	s.notifier.Info(format, desc, re.displayName())
+	return
+}
+
+// reportSize returns the size in bytes associated with the report entry,
+// if the operation has one
+func reportSize(re *reportEntry) (size int64, ok bool) {
+	switch re.note {
+	case OpListing, hrx.OpAppended, hrx.OpUpdated:
+		switch v := re.argv[0].(type) {
+		case string:
+			return int64(len(v)), true
+		case int:
+			// streamed, only the size is reported
+			return int64(v), true
+		}
 	case hrx.OpCreated:
 		if arg := re.argv[0].(string); path.IsDir(arg) {
-			desc = humanize.Bytes(0)
+			return 0, true
 		} else {
-			desc = humanize.Bytes(uint64(path.FileSize(arg)))
+			return path.FileSize(arg), true
 		}
-	case hrx.OpAppended:
-		desc = humanize.Bytes(uint64(len(re.argv[0].(string))))
+	case hrx.OpDeleted:
+		return int64(re.argv[0].(int)), true
 	case hrx.OpExtracted:
-		fullname := re.argv[0].(string)
-		desc = humanize.Bytes(uint64(path.FileSize(fullname)))
-	default:
-		desc = re.note
-	}
-	if comment != "" {
-		Notifier.Info(format, desc, re.pathname, comment)
-		return
+		if len(re.argv) > 1 {
+			// dry run, projected size
+			return int64(re.argv[1].(int)), true
+		}
+		return path.FileSize(re.argv[0].(string)), true
 	}
-	Notifier.Info(format, desc, re.pathname)
 	return
 }
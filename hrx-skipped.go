@@ -0,0 +1,70 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// Skipped describes a single file or directory that was left out of an
+// archive while walking directories
+type Skipped struct {
+	// Pathname is the archive pathname the file would have had, directories
+	// have a trailing slash
+	Pathname string
+	// Source is the path of the file on disk
+	Source string
+	// Err is the reason the file was skipped, one of ErrNotPlainText,
+	// ErrNotRegular, ErrIgnored, ErrNotSelected or ErrTooLarge
+	Err error
+}
+
+func (s *Skipped) Error() string {
+	return fmt.Sprintf("%s: %v", s.Pathname, s.Err)
+}
+
+func (s *Skipped) Unwrap() error {
+	return s.Err
+}
+
+// newSkipped returns a new Skipped instance with the reason derived from the
+// error given
+func newSkipped(name, source string, err error) (s *Skipped) {
+	if errors.Is(err, hrx.ErrInvalidUnicode) {
+		err = ErrNotPlainText
+	} else {
+		for _, reason := range []error{ErrNotPlainText, ErrNotRegular, ErrIgnored, ErrNotSelected, ErrTooLarge} {
+			if errors.Is(err, reason) {
+				err = reason
+				break
+			}
+		}
+	}
+	return &Skipped{Pathname: name, Source: source, Err: err}
+}
+
+// skipFile reports the skipped file, or returns an error when the
+// Options.Strict setting is true. Files left out by the Options.Include and
+// Options.Exclude patterns are always reported, these are not accidental
+func skipFile(s *session, src string, skip *Skipped) (err error) {
+	if s.opt.Strict && !errors.Is(skip.Err, ErrNotSelected) {
+		return fmt.Errorf("%w: %q", skip.Err, skip.Source)
+	}
+	s.report(src, skip.Pathname, hrx.OpSkipped, skip)
+	return
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/maruel/natural"
+
+	"github.com/go-corelibs/hrx"
+)
+
+const (
+	// SortNone is the Options.Sort for keeping the order of the pathnames
+	// given and the files found within them, this is the default
+	SortNone = "none"
+	// SortName is the Options.Sort for ordering by pathname, byte by byte
+	SortName = "name"
+	// SortNatural is the Options.Sort for ordering by pathname with any
+	// numbers compared by value, so that "file2" comes before "file10"
+	SortNatural = "natural"
+	// SortMtime is the Options.Sort for ordering by modification time, the
+	// oldest first. Archive entries have no modification time, so entries
+	// listed are ordered by pathname
+	SortMtime = "mtime"
+	// SortSize is the Options.Sort for ordering by size, the smallest first
+	SortSize = "size"
+)
+
+func validateSort(opt *Options) (err error) {
+	switch opt.Sort {
+	case "", SortNone, SortName, SortNatural, SortMtime, SortSize:
+	default:
+		err = fmt.Errorf("%w: %q", ErrInvalidSort, opt.Sort)
+	}
+	return
+}
+
+func isSorting(order string) bool {
+	switch order {
+	case SortName, SortNatural, SortMtime, SortSize:
+		return true
+	}
+	return false
+}
+
+// sortItem is the information something is sorted by, ties are broken by
+// name and then by the original order
+type sortItem struct {
+	index   int
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// sortItems sorts the `items` in the `order` given, stably
+func sortItems(order string, items []*sortItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		switch order {
+		case SortNatural:
+			if natural.Less(a.name, b.name) {
+				return true
+			} else if natural.Less(b.name, a.name) {
+				return false
+			}
+		case SortMtime:
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.Before(b.modTime)
+			}
+		case SortSize:
+			if a.size != b.size {
+				return a.size < b.size
+			}
+		}
+		return a.name < b.name
+	})
+}
+
+// sortPlanned sorts the `plan` by the Options.Sort order, by archive
+// pathname. Kept empty directories have the size of zero and the
+// modification time of the directory
+func sortPlanned(s *session, plan []*planned) {
+	items := make([]*sortItem, len(plan))
+	for idx, p := range plan {
+		item := &sortItem{index: idx, name: p.name}
+		switch s.opt.Sort {
+		case SortMtime:
+			if p.file != "" {
+				item.modTime = s.src.modTime(p.file)
+			} else {
+				item.modTime = s.src.modTime(p.arg)
+			}
+		case SortSize:
+			if p.file != "" {
+				item.size = s.src.fileSize(p.file)
+			}
+		}
+		items[idx] = item
+	}
+	sortItems(s.opt.Sort, items)
+
+	sorted := make([]*planned, len(plan))
+	for idx, item := range items {
+		sorted[idx] = plan[item.index]
+	}
+	copy(plan, sorted)
+}
+
+// sortEntries returns the archive `entries` in the `order` given
+func sortEntries(order string, entries []hrx.Entry) (sorted []hrx.Entry) {
+	if !isSorting(order) {
+		return entries
+	}
+	items := make([]*sortItem, len(entries))
+	for idx, entry := range entries {
+		items[idx] = &sortItem{index: idx, name: entry.GetPathname(), size: int64(len(entry.GetBody()))}
+	}
+	sortItems(order, items)
+
+	sorted = make([]hrx.Entry, len(entries))
+	for idx, item := range items {
+		sorted[idx] = entries[item.index]
+	}
+	return
+}
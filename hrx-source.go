@@ -0,0 +1,186 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/maruel/natural"
+
+	"github.com/go-corelibs/path"
+)
+
+// source is where the files added to an archive are read from
+type source interface {
+	// isFile reports whether the `name` is a file
+	isFile(name string) (ok bool)
+	// listFiles returns all the files within the `dir`, in the same order as
+	// path.ListAllFiles (or path.ListFiles when not recursing)
+	listFiles(dir string, recurse, all bool) (files []string, err error)
+	// validateFile returns an error if the `name` is not a regular file
+	validateFile(name string) (err error)
+	// fileSize returns the size of the `name` file
+	fileSize(name string) (size int64)
+	// readFile returns the contents of the `name` file
+	readFile(name string) (data []byte, err error)
+	// modTime returns the modification time of the `name` file or directory
+	modTime(name string) (modTime time.Time)
+}
+
+// gOsSource is the source for the local filesystem
+var gOsSource source = osSource{}
+
+type osSource struct{}
+
+func (osSource) isFile(name string) (ok bool) {
+	return path.IsFile(name)
+}
+
+func (osSource) listFiles(dir string, recurse, all bool) (files []string, err error) {
+	if recurse {
+		return path.ListAllFiles(dir, all)
+	}
+	return path.ListFiles(dir, all)
+}
+
+func (osSource) validateFile(name string) (err error) {
+	return validateExistingFile(name)
+}
+
+func (osSource) fileSize(name string) (size int64) {
+	return path.FileSize(name)
+}
+
+func (osSource) readFile(name string) (data []byte, err error) {
+	return os.ReadFile(name)
+}
+
+func (osSource) modTime(name string) (modTime time.Time) {
+	if info, err := os.Stat(name); err == nil {
+		modTime = info.ModTime()
+	}
+	return
+}
+
+// fsSource is the source for an fs.FS, using slash-separated names
+type fsSource struct {
+	fsys fs.FS
+}
+
+// name returns the fs.FS name for the pathname given
+func (fsSource) name(pathname string) (name string) {
+	return filepath.ToSlash(filepath.Clean(pathname))
+}
+
+func (s fsSource) isFile(name string) (ok bool) {
+	info, err := fs.Stat(s.fsys, s.name(name))
+	return err == nil && !info.IsDir()
+}
+
+func (s fsSource) listFiles(dir string, recurse, all bool) (files []string, err error) {
+	name := s.name(dir)
+	var info fs.FileInfo
+	if info, err = fs.Stat(s.fsys, name); err != nil {
+		return
+	} else if !info.IsDir() {
+		if all || !path.IsHidden(name) {
+			files = append(files, name)
+		}
+		return
+	}
+	return s.listDir(name, recurse, all)
+}
+
+// listDir lists the files within the `dir` with hidden things first and
+// nested directories before files, each sorted in natural order
+func (s fsSource) listDir(dir string, recurse, all bool) (files []string, err error) {
+	var entries []fs.DirEntry
+	if entries, err = fs.ReadDir(s.fsys, dir); err != nil {
+		return
+	}
+
+	var hiddenDirs, normalDirs, hiddenFiles, normalFiles []string
+	for _, entry := range entries {
+		hidden := path.IsHidden(entry.Name())
+		if hidden && !all {
+			continue
+		}
+		name := entry.Name()
+		if dir != "." {
+			name = dir + "/" + name
+		}
+		switch {
+		case entry.IsDir() && hidden:
+			hiddenDirs = append(hiddenDirs, name)
+		case entry.IsDir():
+			normalDirs = append(normalDirs, name)
+		case hidden:
+			hiddenFiles = append(hiddenFiles, name)
+		default:
+			normalFiles = append(normalFiles, name)
+		}
+	}
+	for _, list := range [][]string{hiddenDirs, normalDirs, hiddenFiles, normalFiles} {
+		sort.Sort(natural.StringSlice(list))
+	}
+
+	if recurse {
+		for _, name := range append(hiddenDirs, normalDirs...) {
+			var found []string
+			if found, err = s.listDir(name, recurse, all); err != nil {
+				return
+			}
+			files = append(files, found...)
+		}
+	}
+	files = append(files, append(hiddenFiles, normalFiles...)...)
+	return
+}
+
+func (s fsSource) validateFile(name string) (err error) {
+	var info fs.FileInfo
+	if info, err = fs.Stat(s.fsys, s.name(name)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %q", ErrFileNotFound, name)
+	} else if err != nil {
+		return
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %q", ErrNotRegular, name)
+	}
+	return
+}
+
+func (s fsSource) fileSize(name string) (size int64) {
+	if info, err := fs.Stat(s.fsys, s.name(name)); err == nil {
+		size = info.Size()
+	}
+	return
+}
+
+func (s fsSource) readFile(name string) (data []byte, err error) {
+	return fs.ReadFile(s.fsys, s.name(name))
+}
+
+func (s fsSource) modTime(name string) (modTime time.Time) {
+	if info, err := fs.Stat(s.fsys, s.name(name)); err == nil {
+		modTime = info.ModTime()
+	}
+	return
+}
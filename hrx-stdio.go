@@ -0,0 +1,92 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"context"
+	"io"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// ListFrom is like List except that the archive is read from the given
+// io.Reader
+func ListFrom(r io.Reader, pathnames ...string) (err error) {
+	return ListFromContext(context.Background(), nil, r, pathnames...)
+}
+
+// ListFromContext is like ListFrom except that the Options given are used and
+// the `ctx` is checked in the same way as ListContext
+func ListFromContext(ctx context.Context, opt *Options, r io.Reader, pathnames ...string) (err error) {
+	var a hrx.Archive
+	if a, err = prepareReaderSrc(r); err != nil {
+		return
+	}
+	err = listEntries(newSession(ctx, opt), a, gStdioName, pathnames...)
+	return
+}
+
+// CreateTo is like Create except that the archive is written to the given
+// io.Writer instead of a file
+func CreateTo(opt *Options, w io.Writer, pathnames ...string) (a hrx.Archive, skipped []*Skipped, err error) {
+	return CreateToContext(context.Background(), opt, w, pathnames...)
+}
+
+// CreateToContext is like CreateTo except that the `ctx` is checked in the
+// same way as CreateContext and nothing is written to `w` once it is done
+func CreateToContext(ctx context.Context, opt *Options, w io.Writer, pathnames ...string) (a hrx.Archive, skipped []*Skipped, err error) {
+	if len(pathnames) == 0 {
+		err = ErrPathRequired
+		return
+	}
+	a = hrx.New(gStdioName, "")
+	s := newSession(ctx, opt)
+	if skipped, err = createEntries(s, a, pathnames...); err != nil {
+		a, skipped = nil, nil
+		return
+	} else if err = s.checkContext(gStdioName); err != nil {
+		a, skipped = nil, nil
+		return
+	}
+
+	if s.opt.DryRun {
+		s.printSummary(a, OpArchived, gStdioName)
+	} else if err = writeArchive(w, a); err != nil {
+		a, skipped = nil, nil
+	} else {
+		s.printSummary(a, OpArchived, gStdioName)
+	}
+	return
+}
+
+// ExtractFrom is like Extract except that the archive is read from the
+// given io.Reader. If `dst` is empty, the current directory is used
+func ExtractFrom(opt *Options, r io.Reader, dst string, pathnames ...string) (err error) {
+	return ExtractFromContext(context.Background(), opt, r, dst, pathnames...)
+}
+
+// ExtractFromContext is like ExtractFrom except that the `ctx` is checked in
+// the same way as ExtractContext
+func ExtractFromContext(ctx context.Context, opt *Options, r io.Reader, dst string, pathnames ...string) (err error) {
+	var a hrx.Archive
+	if a, err = prepareReaderSrc(r); err != nil {
+		return
+	}
+	if dst == "" {
+		dst = "."
+	}
+	err = extractEntries(newSession(ctx, opt), a, gStdioName, dst, pathnames...)
+	return
+}
@@ -0,0 +1,363 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-corelibs/hrx"
+	"github.com/go-corelibs/path"
+)
+
+// CreateStream is like Create except that each file is written to the `dst`
+// archive as it is read, instead of holding the entire archive in memory,
+// so that memory use is proportional to the largest file instead of the
+// total size of all the files. The archive written is the same as the one
+// Create writes for the same `pathnames` and Options, though files are read
+// one at a time regardless of Options.Workers. With BoundaryAuto, all the
+// files are read once before any are written, to choose the boundary
+//
+// The archive is written to a temporary file alongside `dst` which replaces
+// `dst` only once all the files have been written
+func CreateStream(opt *Options, dst string, pathnames ...string) (skipped []*Skipped, err error) {
+	return CreateStreamContext(context.Background(), opt, dst, pathnames...)
+}
+
+// CreateStreamContext is like CreateStream except that it stops at the next
+// file once the `ctx` is done, returning the context error and leaving any
+// existing `dst` archive untouched
+func CreateStreamContext(ctx context.Context, opt *Options, dst string, pathnames ...string) (skipped []*Skipped, err error) {
+	if len(pathnames) == 0 {
+		err = ErrPathRequired
+		return
+	} else if err = validateNewSrc(dst); err != nil {
+		return
+	}
+	s := newSession(ctx, opt)
+
+	var sw *streamWriter
+	if sw, err = newStreamWriter(s, dst); err != nil {
+		return
+	} else if s.opt.DryRun {
+		if skipped, err = sw.stream(io.Discard, pathnames...); err == nil {
+			sw.summary()
+		}
+		return
+	}
+
+	var fh *os.File
+	if fh, err = os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = fh.Close()
+			_ = os.Remove(fh.Name())
+		}
+	}()
+
+	if skipped, err = sw.stream(fh, pathnames...); err != nil {
+		skipped = nil
+		return
+	}
+
+	// the same permissions as hrx.Archive.WriteFile
+	perms := os.FileMode(0640)
+	if v, ee := path.Permissions(dst); ee == nil {
+		perms = v
+	}
+	if err = fh.Chmod(perms); err == nil {
+		if err = fh.Close(); err == nil {
+			err = os.Rename(fh.Name(), dst)
+		}
+	}
+	if err != nil {
+		skipped = nil
+		return
+	}
+	sw.summary()
+	return
+}
+
+// CreateStreamTo is like CreateStream except that the archive is written to
+// the io.Writer given
+func CreateStreamTo(opt *Options, w io.Writer, pathnames ...string) (skipped []*Skipped, err error) {
+	return CreateStreamToContext(context.Background(), opt, w, pathnames...)
+}
+
+// CreateStreamToContext is like CreateStreamTo except that the `ctx` is
+// checked between each entry and once it is done, the `ctx` error is returned
+// wrapped with the pathname being read. Entries already written to `w` are
+// not taken back
+func CreateStreamToContext(ctx context.Context, opt *Options, w io.Writer, pathnames ...string) (skipped []*Skipped, err error) {
+	if len(pathnames) == 0 {
+		err = ErrPathRequired
+		return
+	}
+	s := newSession(ctx, opt)
+
+	var sw *streamWriter
+	if sw, err = newStreamWriter(s, gStdioName); err != nil {
+		return
+	} else if s.opt.DryRun {
+		w = io.Discard
+	}
+	if skipped, err = sw.stream(w, pathnames...); err != nil {
+		skipped = nil
+	} else {
+		sw.summary()
+	}
+	return
+}
+
+// streamWriter writes archive entries as they are read, in the same format
+// as hrx.Archive.WriteFile
+type streamWriter struct {
+	s        *session
+	dst      string
+	boundary string
+	// index has every entry written, without the bodies, for the summary
+	index hrx.Archive
+	// size is the number of bytes written
+	size uint64
+	// separate is true when the previous entry was a file, which is
+	// separated from the next entry by an empty line
+	separate bool
+	// comment is the archive comment, written after all the entries
+	comment   string
+	commented bool
+}
+
+func newStreamWriter(s *session, dst string) (sw *streamWriter, err error) {
+	if err = validateOptions(s.opt); err != nil {
+		return
+	}
+	sw = &streamWriter{
+		s:     s,
+		dst:   dst,
+		index: hrx.New(dst, ""),
+	}
+	if sw.comment, sw.commented, err = archiveComment(s.opt); err != nil {
+		sw = nil
+	}
+	return
+}
+
+// setBoundary sets the boundary of the entries to be written, choosing the
+// size from the contents and comments of the `plan` with BoundaryAuto. The
+// contents are always scanned first, so that ErrBoundaryCollision is returned
+// before anything is written, the same way Create does
+func (sw *streamWriter) setBoundary(plan []*planned) (err error) {
+	scan := make(boundaryScan)
+	for _, p := range plan {
+		if err = sw.s.checkContext(p.name); err != nil {
+			return
+		}
+		var body string
+		if p.file != "" {
+			// any errors are reported when the file is written
+			fc := readFileContents(sw.s, p.file, p.name)
+			if fc.err != nil || !utf8.ValidString(fc.contents) {
+				continue
+			}
+			body = fc.contents
+		}
+		text, _ := entryComment(sw.s.opt, p.name)
+		if err = scan.addEntry(p.name, body, text); err != nil {
+			return
+		}
+	}
+	if err = scan.addEntry(sw.dst, "", sw.comment); err != nil {
+		return
+	}
+	size := sw.s.opt.Boundary
+	if size == BoundaryAuto {
+		size = scan.pick()
+	} else if _, collides := scan[size]; collides {
+		err = fmt.Errorf("%w: %q", ErrBoundaryCollision, sw.dst)
+		return
+	}
+	sw.boundary = boundaryLine(size)
+	_ = sw.index.SetBoundary(size)
+	// the same report as hrx.Archive.SetBoundary
+	sw.s.report(sw.dst, "", hrx.OpBoundary, hrx.DefaultBoundary, size)
+	return
+}
+
+// stream writes the archive of the `pathnames` to the io.Writer given. All
+// the candidates are found first so that a pathname given more than once is
+// written once, at the position and with the contents Create would use
+func (sw *streamWriter) stream(w io.Writer, pathnames ...string) (skipped []*Skipped, err error) {
+	s := sw.s
+
+	var plan []*planned
+	if skipped, err = walkPathnames(s, func(arg string, found []*candidate, walked bool) ([]*Skipped, error) {
+		for _, c := range found {
+			plan = append(plan, &planned{arg: arg, walked: walked, candidate: c})
+		}
+		return nil, nil
+	}, pathnames...); err != nil {
+		return
+	} else if err = sw.setBoundary(plan); err != nil {
+		return
+	}
+
+	// the plan indexes of each pathname given more than once
+	repeated := make(map[string][]int)
+	for idx, p := range plan {
+		repeated[p.name] = append(repeated[p.name], idx)
+	}
+
+	written := make(map[string]struct{})
+	for idx, p := range plan {
+		if _, present := written[p.name]; present {
+			continue
+		} else if err = s.checkContext(p.name); err != nil {
+			return
+		}
+
+		// the same entry comment as fileContents.set
+		comment, _ := entryComment(s.opt, p.name)
+
+		if p.file == "" {
+			// kept empty directory
+			written[p.name] = struct{}{}
+			if err = sw.write(w, p.name, "", comment); err == nil {
+				s.report(sw.dst, p.name, hrx.OpAppended, 0, comment)
+			}
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var fc *fileContents
+		var skip *Skipped
+		if fc, skip, err = sw.read(p); err != nil {
+			return
+		} else if skip != nil {
+			skipped = append(skipped, skip)
+			continue
+		}
+		written[p.name] = struct{}{}
+		s.report(sw.dst, p.name, hrx.OpAppended, len(fc.contents), comment)
+
+		// the last of any later candidates with the same pathname wins
+		for _, other := range repeated[p.name] {
+			later := plan[other]
+			if other <= idx {
+				continue
+			} else if err = s.checkContext(later.name); err != nil {
+				return
+			}
+			var updated *fileContents
+			if updated, skip, err = sw.read(later); err != nil {
+				return
+			} else if skip != nil {
+				skipped = append(skipped, skip)
+				continue
+			}
+			fc = updated
+			s.report(sw.dst, p.name, hrx.OpUpdated, len(fc.contents), comment)
+		}
+
+		if fc.encoded || isBase64Comment(comment) {
+			comment = setBase64Comment(comment, fc.encoded)
+		}
+		if err = sw.write(w, p.name, fc.contents, comment); err != nil {
+			return
+		}
+	}
+
+	if sw.commented {
+		err = sw.writeComment(w)
+	}
+	return
+}
+
+// read returns the contents of the planned file, or the Skipped instance if
+// the file was skipped
+func (sw *streamWriter) read(p *planned) (fc *fileContents, skip *Skipped, err error) {
+	fc = readFileContents(sw.s, p.file, p.name)
+	if err = fc.err; err == nil && fc.contents != "" && !utf8.ValidString(fc.contents) {
+		// the same error as hrx.Archive.Set
+		err = fmt.Errorf("%w: %q", hrx.ErrInvalidUnicode, p.file)
+	}
+	if err != nil && p.walked {
+		skip, err = skipWalked(sw.s, p.arg, p.candidate, err)
+	}
+	return
+}
+
+// write writes a single entry, in the same format as hrx.Archive.WriteFile
+func (sw *streamWriter) write(w io.Writer, name, body, comment string) (err error) {
+	var buf strings.Builder
+	if sw.separate {
+		buf.WriteString("\n")
+	}
+	if comment != "" {
+		buf.WriteString(sw.boundary + "\n" + comment)
+		if !strings.HasSuffix(comment, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString(sw.boundary + " " + name + "\n")
+
+	var n int
+	if n, err = io.WriteString(w, buf.String()); err == nil {
+		sw.size += uint64(n)
+		if n, err = io.WriteString(w, body); err == nil {
+			sw.size += uint64(n)
+		}
+	}
+	if err == nil {
+		sw.separate = !strings.HasSuffix(name, "/")
+		_ = sw.index.Set(name, "", comment)
+	}
+	return
+}
+
+// writeComment writes the archive comment, in the same format as
+// hrx.Archive.WriteFile
+func (sw *streamWriter) writeComment(w io.Writer) (err error) {
+	var buf strings.Builder
+	if sw.separate {
+		buf.WriteString("\n")
+	}
+	buf.WriteString(sw.boundary + "\n" + sw.comment)
+
+	var n int
+	if n, err = io.WriteString(w, buf.String()); err == nil {
+		sw.size += uint64(n)
+		sw.index.SetComment(sw.comment)
+	}
+	return
+}
+
+// summary prints the summary of the archive written, or the projected
+// summary of a dry run
+func (sw *streamWriter) summary() {
+	if sw.s.opt.DryRun || sw.dst == gStdioName {
+		sw.s.printSummarySize(sw.index, OpArchived, sw.dst, sw.size)
+		return
+	}
+	sw.s.printSummary(sw.index, OpArchived, sw.dst)
+}
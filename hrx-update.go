@@ -0,0 +1,97 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"context"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// Append adds the given `pathnames` to the existing `dst` archive file,
+// according to the Options given. Pathnames already present within the
+// archive are replaced in-place, keeping their original position and any
+// comments associated with them, unless replaced by Options.EntryComments.
+// The boundary of the existing archive is preserved unless a nested archive
+// added collides with it, in which case a larger boundary is chosen when
+// Options.Boundary is BoundaryAuto and ErrBoundaryCollision is returned
+// otherwise. Any other contents are refused the same way as with Create
+func Append(opt *Options, dst string, pathnames ...string) (a hrx.Archive, err error) {
+	return modifyExisting(context.Background(), opt, dst, false, pathnames...)
+}
+
+// AppendContext is like Append except that the `ctx` is checked between each
+// file read and when the `ctx` is done, the `dst` archive is left as-is and
+// the `ctx` error is returned wrapped with the pathname being read
+func AppendContext(ctx context.Context, opt *Options, dst string, pathnames ...string) (a hrx.Archive, err error) {
+	return modifyExisting(ctx, opt, dst, false, pathnames...)
+}
+
+// Update is like Append except that pathnames already present within the
+// `dst` archive are only replaced when their contents differ
+func Update(opt *Options, dst string, pathnames ...string) (a hrx.Archive, err error) {
+	return modifyExisting(context.Background(), opt, dst, true, pathnames...)
+}
+
+// UpdateContext is like Update except that the `ctx` is checked in the same
+// way as AppendContext
+func UpdateContext(ctx context.Context, opt *Options, dst string, pathnames ...string) (a hrx.Archive, err error) {
+	return modifyExisting(ctx, opt, dst, true, pathnames...)
+}
+
+func modifyExisting(ctx context.Context, opt *Options, dst string, changedOnly bool, pathnames ...string) (a hrx.Archive, err error) {
+	if len(pathnames) == 0 {
+		err = ErrPathRequired
+		return
+	} else if a, err = prepareExistingSrc(dst); err != nil {
+		a = nil
+		return
+	}
+	s := newSession(ctx, opt)
+	if err = validateOptions(s.opt); err != nil {
+		a = nil
+		return
+	}
+	var comment string
+	var commented bool
+	if comment, commented, err = archiveComment(s.opt); err != nil {
+		a = nil
+		return
+	}
+	a.SetReporter(s.report)
+
+	if _, err = setPathnames(s, a, changedOnly, pathnames...); err != nil {
+		a = nil
+		return
+	} else if commented {
+		a.SetComment(comment)
+	}
+	if a, err = settleBoundary(s, a); err != nil {
+		a = nil
+		return
+	} else if err = s.checkContext(dst); err != nil {
+		a = nil
+		return
+	}
+
+	if s.opt.DryRun {
+		s.printSummarySize(a, hrx.OpAppended, dst, archiveSize(a))
+	} else if err = a.WriteFile(dst); err != nil {
+		a = nil
+	} else {
+		s.printSummary(a, hrx.OpAppended, dst)
+	}
+	return
+}
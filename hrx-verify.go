@@ -0,0 +1,235 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// Problem describes a single HRX specification violation found by Verify
+type Problem struct {
+	// Line is the line number of the archive where the problem was found
+	Line int
+	// Pathname is the entry pathname associated with the problem, if any
+	Pathname string
+	// Err is the specific problem found
+	Err error
+}
+
+func (p *Problem) Error() string {
+	if p.Pathname != "" {
+		return fmt.Sprintf("%d: %s: %v", p.Line, p.Pathname, p.Err)
+	}
+	return fmt.Sprintf("%d: %v", p.Line, p.Err)
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// Verify checks the existing `src` archive file against the HRX
+// specification and returns all the problems found, in line number order.
+// Unlike parsing the archive, Verify does not stop at the first problem
+// found. Each problem is also reported using the package Notifier
+func Verify(src string) (problems []*Problem, err error) {
+	return VerifyWith(nil, src)
+}
+
+// VerifyWith is like Verify except that the problems are reported using the
+// Notifier of the Options given
+func VerifyWith(opt *Options, src string) (problems []*Problem, err error) {
+	if err = validateExistingFile(src); err != nil {
+		return
+	}
+	var fh *os.File
+	if fh, err = os.Open(src); err != nil {
+		return
+	}
+	defer fh.Close()
+
+	s := newSession(context.Background(), opt)
+	problems = verifyReader(fh)
+	for _, problem := range problems {
+		s.notifier.Error("%s:%v\n", src, problem)
+	}
+	if len(problems) == 0 {
+		s.notifier.Info("%s: ok\n", src)
+	}
+	return
+}
+
+type verifyEntry struct {
+	line     int
+	pathname string
+}
+
+func (ve *verifyEntry) isComment() bool {
+	return ve.pathname == ""
+}
+
+func (ve *verifyEntry) isDir() bool {
+	return strings.HasSuffix(ve.pathname, "/")
+}
+
+func (ve *verifyEntry) isHRX() bool {
+	return strings.HasSuffix(ve.pathname, ".hrx")
+}
+
+type verifier struct {
+	boundary int
+	current  *verifyEntry
+	seen     map[string]int
+	files    map[string]int
+	dirs     map[string]int
+	problems []*Problem
+}
+
+func verifyReader(r io.Reader) (problems []*Problem) {
+	v := &verifier{
+		seen:  make(map[string]int),
+		files: make(map[string]int),
+		dirs:  make(map[string]int),
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		v.problem(0, "", err)
+		return v.problems
+	} else if strings.TrimSpace(string(data)) == "" {
+		v.problem(0, "", hrx.ErrEmptyArchive)
+		return v.problems
+	}
+
+	for s := hrx.NewScanner(bytes.NewReader(data)); s.Scan(); {
+		content, line, boundary, pathname, header, err := s.Get()
+
+		if v.current == nil {
+			// this is the first line
+			if !header {
+				v.problem(line, "", hrx.ErrBadArchiveHeader)
+				return v.problems
+			}
+			v.boundary = boundary
+		}
+
+		if header && boundary == v.boundary {
+			v.verifyHeader(line, pathname, err)
+			continue
+		}
+
+		v.verifyContent(line, content, boundary, header)
+	}
+
+	v.verifyShadows()
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		return v.problems[i].Line < v.problems[j].Line
+	})
+	return v.problems
+}
+
+func (v *verifier) problem(line int, pathname string, err error) {
+	v.problems = append(v.problems, &Problem{Line: line, Pathname: pathname, Err: err})
+}
+
+func (v *verifier) verifyHeader(line int, pathname string, err error) {
+	if err != nil {
+		v.problem(line, pathname, err)
+	}
+
+	this := &verifyEntry{line: line, pathname: pathname}
+	if this.isComment() {
+		if v.current != nil && v.current.isComment() {
+			v.problem(line, "", hrx.ErrSequentialComments)
+		}
+		v.current = this
+		return
+	}
+	v.current = this
+
+	if err = verifyPathComponents(pathname); err != nil {
+		v.problem(line, pathname, err)
+	}
+
+	if previous, present := v.seen[pathname]; present {
+		v.problem(line, pathname, fmt.Errorf("%w, first seen on line %d", hrx.ErrDuplicatePath, previous))
+	} else {
+		v.seen[pathname] = line
+	}
+
+	name := strings.TrimSuffix(pathname, "/")
+	parts := strings.Split(name, "/")
+	for idx := range parts[:len(parts)-1] {
+		parent := strings.Join(parts[:idx+1], "/")
+		if _, present := v.dirs[parent]; !present {
+			v.dirs[parent] = line
+		}
+	}
+	if this.isDir() {
+		if _, present := v.dirs[name]; !present {
+			v.dirs[name] = line
+		}
+	} else {
+		v.files[name] = line
+	}
+}
+
+func (v *verifier) verifyContent(line int, content string, boundary int, header bool) {
+	if !utf8.ValidString(content) {
+		v.problem(line, v.current.pathname, hrx.ErrInvalidUnicode)
+	}
+
+	if header && boundary > 0 && !v.current.isHRX() {
+		// looks like a boundary, but not for this archive and is not part
+		// of an embedded archive either
+		v.problem(line, v.current.pathname, fmt.Errorf("%w: found %d, expected %d", ErrInconsistentBoundary, boundary, v.boundary))
+	}
+
+	if v.current.isDir() && strings.TrimSpace(content) != "" {
+		v.problem(line, v.current.pathname, hrx.ErrDirectoryHasContents)
+	}
+}
+
+func (v *verifier) verifyShadows() {
+	for name, line := range v.files {
+		if dirLine, present := v.dirs[name]; present {
+			// report where the conflict happens, not where it starts
+			v.problem(max(line, dirLine), name, hrx.ErrFileAsParentDir)
+		}
+	}
+}
+
+func verifyPathComponents(pathname string) (err error) {
+	if strings.HasPrefix(pathname, "/") {
+		return hrx.ErrStartsWithDirSep
+	} else if strings.Contains(pathname, "//") {
+		return hrx.ErrContainsRelPath
+	}
+	for _, name := range strings.Split(strings.TrimSuffix(pathname, "/"), "/") {
+		switch name {
+		case ".", "..":
+			return hrx.ErrContainsRelPath
+		}
+	}
+	return
+}
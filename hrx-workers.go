@@ -0,0 +1,56 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrx
+
+import (
+	"sync"
+)
+
+// readFilesConcurrently reads the `found` files using up to Options.Workers
+// goroutines, returning the contents in the same order as `found`. Files
+// not read, because there is only one worker or the session context is
+// done, are nil
+func readFilesConcurrently(s *session, found []*candidate) (read []*fileContents) {
+	read = make([]*fileContents, len(found))
+	workers := s.opt.Workers
+	if workers > len(found) {
+		workers = len(found)
+	}
+	if workers < 2 {
+		return
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for count := 0; count < workers; count++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				read[idx] = readFileContents(s, found[idx].file, found[idx].name)
+			}
+		}()
+	}
+
+	for idx := range found {
+		if s.ctx.Err() != nil {
+			break
+		}
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+	return
+}
@@ -17,19 +17,29 @@
 package hrx
 
 import (
-	"os"
+	"context"
+	"io"
+	"io/fs"
 	"path/filepath"
 	"strings"
 
 	"github.com/go-corelibs/hrx"
-	clPath "github.com/go-corelibs/path"
-	"github.com/go-corelibs/tdata"
+	"github.com/go-corelibs/notify"
 )
 
 const (
 	OpWrote    = "wrote"
 	OpListing  = "listing"
 	OpArchived = "archived"
+
+	OpKept     = "kept"
+	OpNewer    = "newer"
+	OpBackedUp = "backed-up"
+
+	OpCompared    = "compared"
+	OpDiffers     = "differs"
+	OpArchiveOnly = "only-in-hrx"
+	OpDirOnly     = "only-in-dir"
 )
 
 // Options are the complete configurable options for Create and Extract
@@ -41,7 +51,11 @@ type Options struct {
 	// PruneDir specifies to prune the top directory from files added to the
 	// Archive
 	PruneDir bool
-	// Boundary specifies the Archive boundary size to use
+	// Boundary specifies the Archive boundary size to use, when BoundaryAuto
+	// the smallest size from hrx.DefaultBoundary which does not collide with
+	// any nested archives is used. Any other contents or comments with lines
+	// that look like a boundary, of any size, are refused with
+	// ErrBoundaryCollision as these would not parse back the same
 	Boundary int
 	// TrimPrefix specifies an arbitrary string prefix to trim from files
 	// added to the Archive
@@ -49,32 +63,118 @@ type Options struct {
 	// KeepEmpty specifies to include empty directories when added to the
 	// Archive
 	KeepEmpty bool
+	// Unified specifies to include a unified diff of any differing contents
+	// found during a Diff
+	Unified bool
+	// Include specifies glob patterns of pathnames to select, when empty all
+	// pathnames are selected. Patterns without a slash match any component
+	// of a pathname, otherwise the whole pathname is matched and "**"
+	// matches any number of directories
+	Include []string
+	// Exclude specifies glob patterns of pathnames to skip, using the same
+	// syntax as Include. Exclude patterns take precedence over Include
+	Exclude []string
+	// NoIgnore specifies to not read any .hrxignore files found within the
+	// directories walked
+	NoIgnore bool
+	// GitIgnore specifies to also read any .gitignore files found within the
+	// directories walked
+	GitIgnore bool
+	// MaxSize specifies the maximum size in bytes of files added to the
+	// Archive while walking directories, larger files are skipped. Zero
+	// means no limit
+	MaxSize int64
+	// Strict specifies that any file skipped while walking directories is
+	// an error, except for those not selected by Include and Exclude
+	Strict bool
+	// UnsafePaths specifies to allow extracting entries to paths outside of
+	// the destination directory, including through existing symlinks, only
+	// use this with trusted archives
+	UnsafePaths bool
+	// Overwrite specifies what happens to existing files when extracting,
+	// one of OverwriteAlways (the default), OverwriteKeep,
+	// OverwriteSkipNewer or OverwriteBackup
+	Overwrite string
+	// DryRun specifies to report what would be archived, extracted or
+	// deleted, without writing anything
+	DryRun bool
+	// Format specifies the output format of the summary, one of FormatTable
+	// (the default), FormatJSON, FormatNDJSON or FormatCSV. Structured
+	// formats are always written, regardless of the Notifier level
+	Format string
+	// Output specifies where structured Format records are written, when nil
+	// os.Stdout is used
+	Output io.Writer
+	// Binary specifies how files that are not valid UTF-8 text are handled
+	// when added to the Archive, one of BinarySkip (the default),
+	// BinaryError or BinaryBase64. BinaryBase64 also encodes text files with
+	// lines that look like a boundary, other than nested archives
+	Binary string
+	// Notifier is the user notice output handler for this operation, when
+	// nil the package Notifier is used
+	Notifier notify.Notifier
+	// Workers is the number of files read concurrently when walking
+	// directories, the archive entries are still added in the same order.
+	// Values less than two read one file at a time
+	Workers int
+	// Sort specifies the order of the entries added to the Archive, or
+	// listed from it, one of SortNone (the default), SortName, SortNatural,
+	// SortMtime or SortSize. With any order other than SortNone, the same
+	// files always result in the same Archive, regardless of the order of
+	// the pathnames given
+	Sort string
+	// Comment specifies the archive comment to set when creating or
+	// modifying an Archive, replacing any existing archive comment
+	Comment string
+	// CommentFile specifies a file to read the archive comment from, instead
+	// of giving the Comment itself
+	CommentFile string
+	// EntryComments specifies "PATTERN=TEXT" values, setting the comment of
+	// each entry added that matches the PATTERN to the TEXT. The PATTERN
+	// uses the same syntax as Include and when more than one PATTERN
+	// matches, the last one is used
+	EntryComments []string
 }
 
 // List displays a list of pathnames within an existing `src` archive file.
 // If any `pathnames` are given, List will only display those pathnames given
 // that exist within the `src` archive file
 func List(src string, pathnames ...string) (err error) {
+	return ListContext(context.Background(), nil, src, pathnames...)
+}
+
+// ListContext is like List except that the entries are listed according to
+// the Options given and the `ctx` is checked between each entry. Listing
+// stops when the `ctx` is done, returning the `ctx` error wrapped with the
+// pathname being listed
+func ListContext(ctx context.Context, opt *Options, src string, pathnames ...string) (err error) {
 	var a hrx.Archive
 	if a, err = prepareExistingSrc(src); err != nil {
 		return
 	}
-	safeResetReporting()
-	tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
-	for _, entry := range a.Entries() {
-		pathname := entry.GetPathname()
-		if tc.NotPresent(pathname) {
-			continue
-		}
-		reporterFn(src, pathname, OpListing, entry.GetBody())
-	}
-	printSummary(a, OpListing, src)
+	err = listEntries(newSession(ctx, opt), a, src, pathnames...)
 	return
 }
 
 // Create produces an archive with the given `pathnames`, according to the
 // Options given and writes the archive to the `dst` path
-func Create(opt *Options, dst string, pathnames ...string) (a hrx.Archive, err error) {
+//
+// When walking directories, any .hrxignore files found (and .gitignore files
+// when Options.GitIgnore is true) are read using gitignore semantics and the
+// matching files and directories are skipped, unless Options.NoIgnore is true
+//
+// Every file or directory left out while walking directories, or by the
+// Options.Include and Options.Exclude patterns, is reported and returned as a
+// Skipped instance, unless Options.Strict is true in which case the first
+// file skipped, other than by the patterns, is returned as an error instead
+func Create(opt *Options, dst string, pathnames ...string) (a hrx.Archive, skipped []*Skipped, err error) {
+	return CreateContext(context.Background(), opt, dst, pathnames...)
+}
+
+// CreateContext is like Create except that the `ctx` is checked between each
+// file read and when the `ctx` is done, nothing is written to `dst` and the
+// `ctx` error is returned wrapped with the pathname being read
+func CreateContext(ctx context.Context, opt *Options, dst string, pathnames ...string) (a hrx.Archive, skipped []*Skipped, err error) {
 	if len(pathnames) == 0 {
 		err = ErrPathRequired
 		return
@@ -82,55 +182,43 @@ func Create(opt *Options, dst string, pathnames ...string) (a hrx.Archive, err e
 		a = nil
 		return
 	}
-	safeResetReporting()
-	a.SetReporter(reporterFn)
-	opt = prepareOptions(opt)
-	_ = a.SetBoundary(opt.Boundary)
-
-	for _, arg := range pathnames {
-
-		if clPath.IsFile(arg) {
-			if err = readFileAndSet(a, arg, preparePath(opt, arg)); err != nil {
-				a = nil
-				return
-			}
-			continue
-		}
-
-		// is a directory
-		var files []string
-		if opt.Recurse {
-			if files, err = clPath.ListAllFiles(arg, opt.All); err != nil {
-				a = nil
-				return
-			}
-		} else if files, err = clPath.ListFiles(arg, opt.All); err != nil {
-			a = nil
-			return
-		}
-		if len(files) == 0 {
-			// no files found, empty directory or not recursive
-			if opt.KeepEmpty {
-				_ = a.Set(preparePath(opt, arg)+"/", "", "")
-			}
-			continue
-		}
-		for _, name := range files {
-			if err = readFileAndSet(a, name, preparePath(opt, name)); err != nil {
-				if isCreateFileErrIgnored(err) {
-					continue // skip
-				}
-				a = nil
-				return
-			}
-		}
+	s := newSession(ctx, opt)
+	if skipped, err = createEntries(s, a, pathnames...); err != nil {
+		a, skipped = nil, nil
+		return
+	} else if err = s.checkContext(dst); err != nil {
+		a, skipped = nil, nil
+		return
+	}
 
+	if err = writeCreated(s, a, dst); err != nil {
+		a, skipped = nil, nil
 	}
+	return
+}
 
-	if err = a.WriteFile(dst); err != nil {
+// CreateFromFS is like Create except that the `roots` are files and
+// directories within the `fsys` given instead of the local filesystem, such
+// as an embed.FS or an fstest.MapFS. The `roots` are fs.FS names and
+// directories are walked in the same order, with the same Options, as
+// Create does
+func CreateFromFS(opt *Options, fsys fs.FS, dst string, roots ...string) (a hrx.Archive, skipped []*Skipped, err error) {
+	if len(roots) == 0 {
+		err = ErrPathRequired
+		return
+	} else if a, err = prepareNewSrc(dst); err != nil {
 		a = nil
-	} else {
-		printSummary(a, OpArchived, dst)
+		return
+	}
+	s := newSession(context.Background(), opt)
+	s.src = fsSource{fsys: fsys}
+	if skipped, err = createEntries(s, a, roots...); err != nil {
+		a, skipped = nil, nil
+		return
+	}
+
+	if err = writeCreated(s, a, dst); err != nil {
+		a, skipped = nil, nil
 	}
 	return
 }
@@ -138,53 +226,28 @@ func Create(opt *Options, dst string, pathnames ...string) (a hrx.Archive, err e
 // Extract takes an existing `src` archive and extracts it to the `dst`
 // directory, according to the Options given. If `pathnames` are provided,
 // only those pathnames that exist within the `src` archive are extracted
+//
+// Every destination is checked before anything is written and if any entry
+// would be written outside of `dst`, an UnsafePathError naming the entry is
+// returned, unless Options.UnsafePaths is true. Existing symlinks within
+// `dst` that lead outside of it are not followed and any symlink present
+// where a file is to be written is replaced instead of written through
 func Extract(opt *Options, src, dst string, pathnames ...string) (err error) {
+	return ExtractContext(context.Background(), opt, src, dst, pathnames...)
+}
+
+// ExtractContext is like Extract except that the `ctx` is checked between
+// each entry written. When the `ctx` is done, the files and directories
+// created so far are removed, any backups made are restored and the `ctx`
+// error is returned wrapped with the pathname being extracted
+func ExtractContext(ctx context.Context, opt *Options, src, dst string, pathnames ...string) (err error) {
 	var a hrx.Archive
 	if a, err = prepareExistingSrc(src); err != nil {
 		return
 	}
-	safeResetReporting()
-	a.SetReporter(reporterFn)
-	opt = prepareOptions(opt)
-
 	if dst == "" {
 		dst = "./" + strings.TrimSuffix(filepath.Base(src), ".hrx")
 	}
-
-	if err = clPath.MkdirAll(dst); err != nil {
-		return
-	}
-
-	if opt.PruneDir || opt.TrimPrefix != "" {
-		tc := tdata.NewTestCheck(len(pathnames) > 0, pathnames...)
-
-		for _, pathname := range a.List() {
-			if tc.NotPresent(pathname) {
-				continue
-			}
-
-			entry := a.Entry(pathname)
-			pruned := pruneName(pathname, opt.TrimPrefix, opt.PruneDir)
-			destination := filepath.Join(dst, pruned)
-			if entry.IsDir() {
-				if err = os.MkdirAll(destination, 0770); err != nil {
-					return
-				}
-				reporterFn(src, destination, hrx.OpCreated, destination)
-			} else if entry.IsFile() {
-				dirname := filepath.Dir(destination)
-				if err = os.MkdirAll(dirname, 0770); err != nil {
-					return
-				} else if err = os.WriteFile(destination, []byte(entry.GetBody()), 0660); err != nil {
-					return
-				}
-				reporterFn(src, destination, OpWrote)
-			}
-		}
-
-	} else if err = a.ExtractTo(dst, pathnames...); err != nil {
-		return
-	}
-	printSummary(a, hrx.OpExtracted, dst)
+	err = extractEntries(newSession(ctx, opt), a, src, dst, pathnames...)
 	return
 }
@@ -15,9 +15,22 @@
 package hrx
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"github.com/go-corelibs/chdirs"
@@ -42,6 +55,27 @@ func restoreNotifier() {
 	}
 }
 
+// cancelAfter is a context.Context that is cancelled once Done has been
+// called the given number of times
+type cancelAfter struct {
+	context.Context
+	cancel context.CancelFunc
+	checks int
+}
+
+func newCancelAfter(checks int) (ctx *cancelAfter) {
+	ctx = &cancelAfter{checks: checks}
+	ctx.Context, ctx.cancel = context.WithCancel(context.Background())
+	return
+}
+
+func (c *cancelAfter) Done() <-chan struct{} {
+	if c.checks -= 1; c.checks < 0 {
+		c.cancel()
+	}
+	return c.Context.Done()
+}
+
 func Test(t *testing.T) {
 
 	td := tdata.New()
@@ -95,7 +129,7 @@ func Test(t *testing.T) {
 
 		Convey("basic archive creation", func() {
 
-			a, err = Create(
+			a, _, err = Create(
 				nil,
 				tempdir.Join("created.hrx"),
 				"files-in-directories",
@@ -106,17 +140,17 @@ func Test(t *testing.T) {
 			//err = a.WriteFile(tempdir.Join("created.hrx"))
 			//So(err, ShouldBeNil)
 
-			a, err = Create(nil, "")
+			a, _, err = Create(nil, "")
 			So(err, ShouldEqual, ErrPathRequired)
 			So(a, ShouldBeNil)
 
-			a, err = Create(nil, "/dev/null", "nope")
+			a, _, err = Create(nil, "/dev/null", "nope")
 			So(err, ShouldNotBeNil)
 			So(a, ShouldBeNil)
 
 			_ = os.WriteFile(tempdir.Join("already-created.hrx"), []byte("<==>\nonly a comment"), 0440)
 
-			a, err = Create(
+			a, _, err = Create(
 				nil,
 				tempdir.Join("already-created.hrx"),
 				"files-in-directories",
@@ -128,7 +162,7 @@ func Test(t *testing.T) {
 
 		Convey("keep empty directories", func() {
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true, KeepEmpty: true},
 				tempdir.Join("keep-empty.hrx"),
 				"empty-dir",
@@ -137,7 +171,7 @@ func Test(t *testing.T) {
 			So(a, ShouldNotBeNil)
 			So(a.List(), ShouldEqual, []string{"empty-dir/"})
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true, KeepEmpty: true, TrimPrefix: "files-in-directories"},
 				tempdir.Join("keep-empty.hrx"),
 				"empty-dir",
@@ -152,7 +186,7 @@ func Test(t *testing.T) {
 
 		Convey("prune top directories", func() {
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true, PruneDir: true},
 				tempdir.Join("pruned.hrx"),
 				"files-in-directories",
@@ -161,7 +195,7 @@ func Test(t *testing.T) {
 			So(a, ShouldNotBeNil)
 			So(a.List(), ShouldEqual, []string{"dir/file1", "path/to/file2"})
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true, PruneDir: true},
 				tempdir.Join("pruned.hrx"),
 				"files-in-directories/dir/file1",
@@ -171,7 +205,7 @@ func Test(t *testing.T) {
 			So(a, ShouldNotBeNil)
 			So(a.List(), ShouldEqual, []string{"dir/file1", "path/to/file2"})
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true, PruneDir: true},
 				tempdir.Join("broken.hrx"),
 				"/dev/null",
@@ -184,7 +218,7 @@ func Test(t *testing.T) {
 
 		Convey("trim path prefix", func() {
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true, PruneDir: true, TrimPrefix: "path"},
 				tempdir.Join("not-broken.hrx"),
 				"files-in-directories",
@@ -197,7 +231,7 @@ func Test(t *testing.T) {
 
 		Convey("path listing cases", func() {
 
-			a, err = Create(
+			a, _, err = Create(
 				nil,
 				tempdir.Join("listing.hrx"),
 				"empty-dir",
@@ -208,7 +242,7 @@ func Test(t *testing.T) {
 
 			_ = os.Mkdir(tempdir.Join("no-perms-dir"), 0000)
 
-			a, err = Create(
+			a, _, err = Create(
 				nil,
 				tempdir.Join("listing.hrx"),
 				tempdir.Join("no-perms-dir"),
@@ -216,7 +250,7 @@ func Test(t *testing.T) {
 			So(err, ShouldNotBeNil)
 			So(a, ShouldBeNil)
 
-			a, err = Create(
+			a, _, err = Create(
 				&Options{},
 				tempdir.Join("listing.hrx"),
 				tempdir.Join("no-perms-dir"),
@@ -226,7 +260,7 @@ func Test(t *testing.T) {
 
 			_ = os.Mkdir(tempdir.Join("bin-files-dir"), 0750)
 			_ = os.WriteFile(tempdir.Join("bin-files-dir", "binary"), []byte{0xff, 0xfe, 0xfd}, 0640)
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true},
 				tempdir.Join("listing.hrx"),
 				tempdir.Join("bin-files-dir"),
@@ -236,7 +270,7 @@ func Test(t *testing.T) {
 
 			_ = os.Mkdir(tempdir.Join("bad-files-dir"), 0750)
 			_ = os.Symlink("/dev/null", tempdir.Join("bad-files-dir", "dev-null"))
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true},
 				tempdir.Join("listing.hrx"),
 				tempdir.Join("bad-files-dir"),
@@ -246,7 +280,7 @@ func Test(t *testing.T) {
 			So(a.List(), ShouldBeEmpty)
 
 			_ = os.WriteFile(tempdir.Join("bad-files-dir", "write-only"), []byte{}, 0220)
-			a, err = Create(
+			a, _, err = Create(
 				&Options{Recurse: true},
 				tempdir.Join("listing.hrx"),
 				tempdir.Join("bad-files-dir"),
@@ -257,6 +291,1477 @@ func Test(t *testing.T) {
 
 	})
 
+	Convey("Append and Update", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.update.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		archive := tempdir.Join("fid.hrx")
+		_ = os.WriteFile(archive, []byte("<===> dir/file1\nfirst\n<===>\na comment\n<===> dir/file2\nsecond\n"), 0640)
+		_ = os.MkdirAll(tempdir.Join("dir"), 0750)
+		_ = os.WriteFile(tempdir.Join("dir", "file1"), []byte("first"), 0640)
+		_ = os.WriteFile(tempdir.Join("dir", "file2"), []byte("changed\n"), 0640)
+		_ = os.WriteFile(tempdir.Join("dir", "file3"), []byte("third\n"), 0640)
+		opt := &Options{Recurse: true, TrimPrefix: tempdir.Path()}
+
+		a, err := Append(opt, archive)
+		So(err, ShouldEqual, ErrPathRequired)
+		So(a, ShouldBeNil)
+
+		a, err = Update(opt, tempdir.Join("nope.hrx"), tempdir.Join("dir"))
+		So(err, ShouldNotBeNil)
+		So(a, ShouldBeNil)
+
+		a, err = Update(opt, archive, tempdir.Join("dir", "nope"))
+		So(err, ShouldNotBeNil)
+		So(a, ShouldBeNil)
+
+		a, err = Update(opt, archive, tempdir.Join("dir"))
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"dir/file1", "dir/file2", "dir/file3"})
+		body, comment, ok := a.Get("dir/file2")
+		So(ok, ShouldBeTrue)
+		So(body, ShouldEqual, "changed\n")
+		So(comment, ShouldEqual, "a comment\n")
+		So(tempdir.F("fid.hrx"), ShouldEqual, "<===> dir/file1\nfirst\n<===>\na comment\n<===> dir/file2\nchanged\n\n<===> dir/file3\nthird\n")
+
+		a, err = Append(
+			&Options{Recurse: true, Boundary: 4, TrimPrefix: tempdir.Path()},
+			archive,
+			tempdir.Join("dir", "file1"),
+		)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.GetBoundary(), ShouldEqual, 3)
+		So(a.List(), ShouldEqual, []string{"dir/file1", "dir/file2", "dir/file3"})
+		So(tempdir.F("fid.hrx"), ShouldStartWith, "<===> dir/file1\nfirst\n<===>\na comment\n")
+
+	})
+
+	Convey("Delete", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.delete.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		archive := tempdir.Join("directory.hrx")
+		_ = os.WriteFile(archive, []byte(td.F("directory.hrx")), 0640)
+
+		a, err := Delete(archive)
+		So(err, ShouldEqual, ErrPathRequired)
+		So(a, ShouldBeNil)
+
+		a, err = Delete(tempdir.Join("nope.hrx"), "dir/")
+		So(err, ShouldNotBeNil)
+		So(a, ShouldBeNil)
+
+		a, err = Delete(archive, "dir/", "nope")
+		So(err, ShouldNotBeNil)
+		So(a, ShouldBeNil)
+		So(tempdir.F("directory.hrx"), ShouldEqual, td.F("directory.hrx"))
+
+		a, err = Delete(archive, "other/subdir")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"dir/", "dir/subdir/"})
+
+		// an archive without entries cannot be parsed back
+		deleted := tempdir.F("directory.hrx")
+		a, err = Delete(archive, "dir/", "dir/subdir/")
+		So(err, ShouldWrap, ErrDeletesAll)
+		So(a, ShouldBeNil)
+		So(tempdir.F("directory.hrx"), ShouldEqual, deleted)
+
+		a, err = Delete(archive, "dir/subdir/")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"dir/"})
+		_, err = hrx.ParseFile(archive)
+		So(err, ShouldBeNil)
+
+	})
+
+	Convey("Diff", t, func() {
+		backupNotifier()
+		defer restoreNotifier()
+
+		so := stdio.NewStdout()
+		So(so.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer so.Restore()
+
+		tempdir, err := tdata.NewTempData("", "hrx.diff.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		differences, err := Diff(nil, td.Join("simple.hrx"), tempdir.Join("nope"))
+		So(err, ShouldWrap, ErrDirNotFound)
+		So(differences, ShouldEqual, 0)
+
+		differences, err = Diff(nil, tempdir.Join("nope.hrx"), tempdir.Path())
+		So(err, ShouldNotBeNil)
+		So(differences, ShouldEqual, 0)
+
+		differences, err = Diff(
+			&Options{Recurse: true, PruneDir: true},
+			td.Join("files-in-directories.hrx"),
+			"files-in-directories",
+		)
+		So(err, ShouldBeNil)
+		So(differences, ShouldEqual, 0)
+
+		differences, err = Diff(
+			&Options{Recurse: true, PruneDir: true},
+			td.Join("simple.hrx"),
+			"files-in-directories",
+		)
+		So(err, ShouldBeNil)
+		So(differences, ShouldEqual, 4)
+
+		differences, err = Diff(
+			&Options{Recurse: true, PruneDir: true},
+			td.Join("simple.hrx"),
+			"files-in-directories",
+			"input.scss",
+		)
+		So(err, ShouldBeNil)
+		So(differences, ShouldEqual, 1)
+
+		_ = os.WriteFile(tempdir.Join("input.scss"), []byte(td.F("simple/input.scss")), 0640)
+		_ = os.WriteFile(tempdir.Join("output.css"), []byte("changed\n"), 0640)
+		So(so.Reset(), ShouldBeNil)
+		differences, err = Diff(
+			&Options{Recurse: true, Unified: true, TrimPrefix: tempdir.Path()},
+			td.Join("simple.hrx"),
+			tempdir.Path(),
+		)
+		So(err, ShouldBeNil)
+		So(differences, ShouldEqual, 1)
+		sod := string(so.Data())
+		So(sod, ShouldContainSubstring, "    differs | output.css\n")
+		So(sod, ShouldContainSubstring, "\n+changed\n")
+		So(sod, ShouldNotContainSubstring, "input.scss")
+
+	})
+
+	Convey("Verify", t, func() {
+		backupNotifier()
+		defer restoreNotifier()
+
+		so, se := stdio.NewStdout(), stdio.NewStderr()
+		So(so.Capture(), ShouldBeNil)
+		So(se.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer func() {
+			so.Restore()
+			se.Restore()
+		}()
+
+		tempdir, err := tdata.NewTempData("", "hrx.verify.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		problems, err := Verify(tempdir.Join("nope.hrx"))
+		So(err, ShouldWrap, ErrFileNotFound)
+		So(problems, ShouldBeEmpty)
+
+		problems, err = Verify(td.Join("simple.hrx"))
+		So(err, ShouldBeNil)
+		So(problems, ShouldBeEmpty)
+		So(string(so.Data()), ShouldContainSubstring, td.Join("simple.hrx")+": ok\n")
+
+		_ = os.WriteFile(tempdir.Join("broken.hrx"), []byte("<===> one\n<===> /two\n<===> one\n"), 0640)
+		problems, err = Verify(tempdir.Join("broken.hrx"))
+		So(err, ShouldBeNil)
+		So(problems, ShouldHaveLength, 2)
+		sed := string(se.Data())
+		So(sed, ShouldContainSubstring, tempdir.Join("broken.hrx")+":2: /two: "+hrx.ErrStartsWithDirSep.Error()+"\n")
+		So(sed, ShouldContainSubstring, tempdir.Join("broken.hrx")+":3: one: "+hrx.ErrDuplicatePath.Error())
+
+		// problems are reported using the Notifier of the Options given
+		var stdout, stderr bytes.Buffer
+		opt := &Options{Notifier: notify.New(notify.Info).SetOut(&stdout).SetErr(&stderr).Make()}
+		problems, err = VerifyWith(opt, tempdir.Join("broken.hrx"))
+		So(err, ShouldBeNil)
+		So(problems, ShouldHaveLength, 2)
+		So(stderr.String(), ShouldContainSubstring, tempdir.Join("broken.hrx")+":2: /two: "+hrx.ErrStartsWithDirSep.Error()+"\n")
+		_, err = VerifyWith(opt, td.Join("simple.hrx"))
+		So(err, ShouldBeNil)
+		So(stdout.String(), ShouldEqual, td.Join("simple.hrx")+": ok\n")
+		So(string(se.Data()), ShouldEqual, sed)
+
+		_ = os.WriteFile(tempdir.Join("boundary.txt"), []byte("<===> looks like boundary\n"), 0640)
+		_, _, err = Create(nil, tempdir.Join("boundary.hrx"), tempdir.Join("boundary.txt"))
+		So(err, ShouldWrap, ErrBoundaryCollision)
+
+		// header-like lines of other sizes are new entries to the parser
+		_ = os.WriteFile(tempdir.Join("boundary.hrx"), []byte("<=====> boundary.txt\n<===> looks like boundary\n"), 0640)
+		problems, err = Verify(tempdir.Join("boundary.hrx"))
+		So(err, ShouldBeNil)
+		So(problems, ShouldHaveLength, 1)
+		So(problems[0].Line, ShouldEqual, 2)
+		So(problems[0].Pathname, ShouldEqual, "boundary.txt")
+		So(problems[0].Err, ShouldWrap, ErrInconsistentBoundary)
+		So(string(se.Data()), ShouldContainSubstring, tempdir.Join("boundary.hrx")+":2: boundary.txt: "+ErrInconsistentBoundary.Error())
+
+	})
+
+	Convey("Cat", t, func() {
+
+		var buf bytes.Buffer
+		err := Cat("/dev/null", &buf)
+		So(err, ShouldNotBeNil)
+
+		err = Cat(td.Join("simple.hrx"), &buf, "nope")
+		So(err, ShouldWrap, hrx.ErrNotFound)
+		So(buf.String(), ShouldEqual, "")
+
+		err = Cat(td.Join("simple.hrx"), &buf, "output.css")
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, td.F("simple/output.css"))
+
+		buf.Reset()
+		err = Cat(td.Join("directory.hrx"), &buf)
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, "")
+
+		buf.Reset()
+		err = CatWithHeaders(td.Join("files-in-directories.hrx"), &buf)
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, "==> dir/file1 <==\n"+
+			td.F("files-in-directories/dir/file1")+"\n"+
+			"\n==> path/to/file2 <==\n"+
+			td.F("files-in-directories/path/to/file2"),
+		)
+
+	})
+
+	Convey("Stdio", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.stdio.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		Convey("list from reader", func() {
+			backupNotifier()
+			defer restoreNotifier()
+
+			so := stdio.NewStdout()
+			So(so.Capture(), ShouldBeNil)
+			Notifier = notify.New(notify.Info).Make()
+			defer so.Restore()
+
+			err = ListFrom(strings.NewReader("not an archive"))
+			So(err, ShouldNotBeNil)
+
+			err = ListFrom(strings.NewReader(td.F("simple.hrx")), "output.css")
+			So(err, ShouldBeNil)
+			sod := string(so.Data())
+			So(sod, ShouldContainSubstring, "62 B | output.css\n")
+			So(sod, ShouldNotContainSubstring, "input.scss")
+			So(sod, ShouldContainSubstring, " | -\n")
+		})
+
+		Convey("create to writer", func() {
+			var buf bytes.Buffer
+
+			a, _, err := CreateTo(nil, &buf)
+			So(err, ShouldEqual, ErrPathRequired)
+			So(a, ShouldBeNil)
+
+			a, _, err = CreateTo(nil, &buf, "nope")
+			So(err, ShouldNotBeNil)
+			So(a, ShouldBeNil)
+
+			a, _, err = CreateTo(&Options{Recurse: true, PruneDir: true}, &buf, "files-in-directories")
+			So(err, ShouldBeNil)
+			So(a, ShouldNotBeNil)
+
+			a, _, err = Create(&Options{Recurse: true, PruneDir: true}, tempdir.Join("created.hrx"), "files-in-directories")
+			So(err, ShouldBeNil)
+			So(a, ShouldNotBeNil)
+			So(buf.String(), ShouldEqual, tempdir.F("created.hrx"))
+		})
+
+		Convey("extract from reader", func() {
+			err = ExtractFrom(nil, strings.NewReader(""), tempdir.Join("empty.d"))
+			So(err, ShouldNotBeNil)
+
+			err = ExtractFrom(nil, strings.NewReader(td.F("simple.hrx")), tempdir.Join("simple.d"))
+			So(err, ShouldBeNil)
+			So(tempdir.F("simple.d/input.scss"), ShouldEqual, td.F("simple/input.scss"))
+			So(tempdir.F("simple.d/output.css"), ShouldEqual, td.F("simple/output.css"))
+
+			_ = chdirs.Push(tempdir.Path())
+			err = ExtractFrom(&Options{PruneDir: true}, strings.NewReader(td.F("files-in-directories.hrx")), "")
+			_ = chdirs.Pop()
+			So(err, ShouldBeNil)
+			So(tempdir.F("file1"), ShouldEqual, td.F("files-in-directories/dir/file1"))
+			So(tempdir.F("to/file2"), ShouldEqual, td.F("files-in-directories/path/to/file2"))
+		})
+
+	})
+
+	Convey("Include and Exclude", t, func() {
+		backupNotifier()
+		defer restoreNotifier()
+
+		so := stdio.NewStdout()
+		So(so.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer so.Restore()
+
+		tempdir, err := tdata.NewTempData("", "hrx.filter.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		err = ListContext(context.Background(), &Options{Include: []string{"[nope"}}, td.Join("simple.hrx"))
+		So(err, ShouldNotBeNil)
+
+		err = ListContext(context.Background(), &Options{Include: []string{"*.css"}}, td.Join("simple.hrx"))
+		So(err, ShouldBeNil)
+		sod := string(so.Data())
+		So(sod, ShouldContainSubstring, "62 B | output.css\n")
+		So(sod, ShouldNotContainSubstring, "input.scss")
+
+		a, skipped, err := Create(
+			&Options{Recurse: true, Exclude: []string{"file2"}},
+			tempdir.Join("created.hrx"),
+			"files-in-directories",
+		)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"files-in-directories/dir/file1"})
+		So(skipped, ShouldEqual, []*Skipped{{
+			Pathname: "files-in-directories/path/to/file2",
+			Source:   "files-in-directories/path/to/file2",
+			Err:      ErrNotSelected,
+		}})
+		So(string(so.Data()), ShouldContainSubstring, "files-in-directories/path/to/file2 ("+ErrNotSelected.Error()+")")
+
+		// the patterns are deliberate, these are not an error when strict
+		a, skipped, err = Create(
+			&Options{Recurse: true, Strict: true, Include: []string{"**/path/**"}},
+			tempdir.Join("created.hrx"),
+			"files-in-directories",
+			"files-in-directories/dir/file1",
+		)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"files-in-directories/path/to/file2"})
+		So(skipped, ShouldHaveLength, 2)
+		for _, skip := range skipped {
+			So(skip.Pathname, ShouldEqual, "files-in-directories/dir/file1")
+			So(skip.Err, ShouldEqual, ErrNotSelected)
+		}
+
+		err = Extract(
+			&Options{Include: []string{"*.scss"}},
+			td.Join("simple.hrx"),
+			tempdir.Join("simple.d"),
+		)
+		So(err, ShouldBeNil)
+		found, _ := clPath.ListAllFiles(tempdir.Join("simple.d"), true)
+		So(found, ShouldEqual, []string{tempdir.Join("simple.d", "input.scss")})
+
+		err = Extract(
+			&Options{Include: []string{"*.scss"}},
+			td.Join("simple.hrx"),
+			tempdir.Join("none.d"),
+			"output.css",
+		)
+		So(err, ShouldBeNil)
+		found, _ = clPath.ListAllFiles(tempdir.Join("none.d"), true)
+		So(found, ShouldBeEmpty)
+
+		err = Extract(
+			&Options{PruneDir: true, Exclude: []string{"dir/**"}},
+			td.Join("files-in-directories.hrx"),
+			tempdir.Join("fid.d"),
+		)
+		So(err, ShouldBeNil)
+		found, _ = clPath.ListAllFiles(tempdir.Join("fid.d"), true)
+		So(found, ShouldEqual, []string{tempdir.Join("fid.d", "to", "file2")})
+
+	})
+
+	Convey("Ignore Files", t, func() {
+		backupNotifier()
+		defer restoreNotifier()
+
+		so := stdio.NewStdout()
+		So(so.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer so.Restore()
+
+		tempdir, err := tdata.NewTempData("", "hrx.ignore.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		_ = chdirs.Push(tempdir.Path())
+		defer func() { _ = chdirs.Pop() }()
+
+		src := "src"
+		for name, content := range map[string]string{
+			".gitignore":         "*.tmp\n",
+			".hrxignore":         "# build artefacts\n/build/\n*.log\n!keep.log\n",
+			"main.go":            "package main\n",
+			"keep.log":           "kept\n",
+			"debug.log":          "skipped\n",
+			"scratch.tmp":        "scratch\n",
+			"build/output.bin":   "binary\n",
+			"sub/build/file.txt": "not anchored\n",
+			"sub/.hrxignore":     "file.txt\n",
+			"sub/other.txt":      "other\n",
+		} {
+			So(os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0770), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(src, name), []byte(content), 0660), ShouldBeNil)
+		}
+
+		a, skipped, err := Create(&Options{Recurse: true, PruneDir: true}, "default.hrx", src)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(skipped, ShouldEqual, []*Skipped{
+			{Pathname: "build/", Source: "src/build/", Err: ErrIgnored},
+			{Pathname: "sub/build/file.txt", Source: "src/sub/build/file.txt", Err: ErrIgnored},
+			{Pathname: "debug.log", Source: "src/debug.log", Err: ErrIgnored},
+		})
+		So(a.List(), ShouldEqual, []string{
+			"sub/other.txt",
+			"keep.log",
+			"main.go",
+			"scratch.tmp",
+		})
+		sod := string(so.Data())
+		So(sod, ShouldContainSubstring, "skipped | build/ (ignored by pattern) ")
+		So(sod, ShouldContainSubstring, "skipped | debug.log (ignored by pattern) ")
+		So(sod, ShouldContainSubstring, "skipped | sub/build/file.txt (ignored by pattern)\n")
+
+		a, _, err = Create(&Options{Recurse: true, PruneDir: true, GitIgnore: true}, "git.hrx", src)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{
+			"sub/other.txt",
+			"keep.log",
+			"main.go",
+		})
+
+		a, _, err = Create(&Options{Recurse: true, PruneDir: true, Strict: true}, "strict.hrx", src)
+		So(err, ShouldWrap, ErrIgnored)
+		So(a, ShouldBeNil)
+
+		a, _, err = Create(&Options{Recurse: true, PruneDir: true, NoIgnore: true}, "none.hrx", src)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{
+			"build/output.bin",
+			"sub/build/file.txt",
+			"sub/other.txt",
+			"debug.log",
+			"keep.log",
+			"main.go",
+			"scratch.tmp",
+		})
+
+		differences, err := Diff(&Options{Recurse: true, PruneDir: true}, "default.hrx", src)
+		So(err, ShouldBeNil)
+		So(differences, ShouldEqual, 0)
+
+	})
+
+	Convey("Binary Files", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.binary.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		_ = chdirs.Push(tempdir.Path())
+		defer func() { _ = chdirs.Pop() }()
+
+		image := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00, 0xff, 0xfe}
+		So(os.MkdirAll(filepath.Join("src", "img"), 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "img", "logo.png"), image, 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "readme.txt"), []byte("hello\n"), 0660), ShouldBeNil)
+
+		a, skipped, err := Create(&Options{Recurse: true}, "skip.hrx", "src")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"src/readme.txt"})
+		So(skipped, ShouldEqual, []*Skipped{
+			{Pathname: "src/img/logo.png", Source: "src/img/logo.png", Err: ErrNotPlainText},
+		})
+
+		a, skipped, err = Create(&Options{Recurse: true, MaxSize: 5}, "small.hrx", "src")
+		So(err, ShouldBeNil)
+		So(a.List(), ShouldBeEmpty)
+		So(skipped, ShouldHaveLength, 2)
+		So(skipped[0].Err, ShouldEqual, ErrTooLarge)
+		So(skipped[1].Err, ShouldEqual, ErrTooLarge)
+
+		a, _, err = Create(&Options{Recurse: true, Strict: true}, "strict.hrx", "src")
+		So(err, ShouldWrap, ErrNotPlainText)
+		So(a, ShouldBeNil)
+
+		a, _, err = Create(&Options{Recurse: true, Binary: BinaryError}, "error.hrx", "src")
+		So(err, ShouldWrap, ErrNotPlainText)
+		So(a, ShouldBeNil)
+
+		a, _, err = Create(&Options{Recurse: true, Binary: "hex"}, "invalid.hrx", "src")
+		So(err, ShouldWrap, ErrInvalidBinaryMode)
+		So(a, ShouldBeNil)
+
+		a, _, err = Create(&Options{Recurse: true, Binary: BinaryBase64}, "base64.hrx", "src")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"src/img/logo.png", "src/readme.txt"})
+		body, comment, _ := a.Get("src/img/logo.png")
+		So(body, ShouldEqual, "iVBORw0KGgoA//4=\n")
+		So(comment, ShouldEqual, EncodingBase64)
+		_, comment, _ = a.Get("src/readme.txt")
+		So(comment, ShouldEqual, "")
+
+		err = Extract(nil, "base64.hrx", "out")
+		So(err, ShouldBeNil)
+		data, err := os.ReadFile(filepath.Join("out", "src", "img", "logo.png"))
+		So(err, ShouldBeNil)
+		So(data, ShouldEqual, image)
+		data, err = os.ReadFile(filepath.Join("out", "src", "readme.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "hello\n")
+
+		err = Extract(&Options{PruneDir: true}, "base64.hrx", "pruned")
+		So(err, ShouldBeNil)
+		data, err = os.ReadFile(filepath.Join("pruned", "img", "logo.png"))
+		So(err, ShouldBeNil)
+		So(data, ShouldEqual, image)
+
+		var buf bytes.Buffer
+		err = Cat("base64.hrx", &buf, "src/img/logo.png")
+		So(err, ShouldBeNil)
+		So(buf.Bytes(), ShouldEqual, image)
+
+		differences, err := Diff(nil, "base64.hrx", ".", "src/img/logo.png", "src/readme.txt")
+		So(err, ShouldBeNil)
+		So(differences, ShouldEqual, 0)
+
+		So(os.WriteFile(filepath.Join("src", "img", "logo.png"), []byte("now text\n"), 0660), ShouldBeNil)
+		a, err = Update(nil, "base64.hrx", filepath.Join("src", "img", "logo.png"))
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		body, comment, _ = a.Get("src/img/logo.png")
+		So(body, ShouldEqual, "now text\n")
+		So(comment, ShouldEqual, "")
+
+	})
+
+	Convey("Unsafe Paths", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.unsafe.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		_ = chdirs.Push(tempdir.Path())
+		defer func() { _ = chdirs.Pop() }()
+
+		// relative and absolute pathnames are already rejected when parsed
+		So(os.WriteFile("escape.hrx", []byte("<===> ../escaped.txt\nnope\n"), 0660), ShouldBeNil)
+		err = Extract(nil, "escape.hrx", "out")
+		So(err, ShouldWrap, hrx.ErrContainsRelPath)
+		So(clPath.Exists("escaped.txt"), ShouldBeFalse)
+
+		So(os.WriteFile("absolute.hrx", []byte("<===> /escaped.txt\nnope\n"), 0660), ShouldBeNil)
+		err = Extract(nil, "absolute.hrx", "out")
+		So(err, ShouldWrap, hrx.ErrStartsWithDirSep)
+
+		So(os.WriteFile("collapse.hrx", []byte("<===> top/\n<===> top/file.txt\nfine\n<===> loose.txt\nnope\n"), 0660), ShouldBeNil)
+		err = Extract(&Options{PruneDir: true}, "collapse.hrx", "pruned")
+		So(err, ShouldWrap, ErrUnsafePath)
+		var upe *UnsafePathError
+		So(errors.As(err, &upe), ShouldBeTrue)
+		So(upe.Pathname, ShouldEqual, "loose.txt")
+		So(clPath.Exists(filepath.Join("pruned", "file.txt")), ShouldBeFalse)
+
+		err = Extract(&Options{PruneDir: true}, "collapse.hrx", "pruned", "top/", "top/file.txt")
+		So(err, ShouldBeNil)
+		So(clPath.IsFile(filepath.Join("pruned", "file.txt")), ShouldBeTrue)
+
+		// a file named only by the trimmed prefix would replace the destination
+		err = Extract(&Options{TrimPrefix: "top/file.txt"}, "collapse.hrx", "trimmed", "top/file.txt")
+		So(err, ShouldWrap, ErrUnsafePath)
+		So(errors.As(err, &upe), ShouldBeTrue)
+		So(upe.Pathname, ShouldEqual, "top/file.txt")
+		So(upe.Destination, ShouldEqual, "trimmed")
+		found, err := clPath.ListAllFiles("trimmed", true)
+		So(err, ShouldBeNil)
+		So(found, ShouldBeEmpty)
+
+	})
+
+	Convey("Symlink Safety", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.symlinks.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		_ = chdirs.Push(tempdir.Path())
+		defer func() { _ = chdirs.Pop() }()
+
+		// build the hostile layout
+		So(os.MkdirAll("outside", 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("outside", "target.txt"), []byte("original\n"), 0660), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join("dst", "real"), 0770), ShouldBeNil)
+		So(os.Symlink(tempdir.Join("outside"), filepath.Join("dst", "out")), ShouldBeNil)
+		So(os.Symlink(tempdir.Join("outside", "target.txt"), filepath.Join("dst", "link.txt")), ShouldBeNil)
+		So(os.Symlink("real", filepath.Join("dst", "inner")), ShouldBeNil)
+
+		So(os.WriteFile("escape.hrx", []byte("<===> out/target.txt\nreplaced\n"), 0660), ShouldBeNil)
+		err = Extract(nil, "escape.hrx", "dst")
+		So(err, ShouldWrap, ErrUnsafePath)
+		var upe *UnsafePathError
+		So(errors.As(err, &upe), ShouldBeTrue)
+		So(upe.Pathname, ShouldEqual, "out/target.txt")
+		data, err := os.ReadFile(filepath.Join("outside", "target.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "original\n")
+
+		So(os.WriteFile("nested.hrx", []byte("<===> top/out/new.txt\nnew\n"), 0660), ShouldBeNil)
+		err = Extract(&Options{PruneDir: true}, "nested.hrx", "dst")
+		So(err, ShouldWrap, ErrUnsafePath)
+		So(clPath.Exists(filepath.Join("outside", "new.txt")), ShouldBeFalse)
+
+		So(os.WriteFile("replace.hrx", []byte("<===> link.txt\nreplaced\n"), 0660), ShouldBeNil)
+		err = Extract(nil, "replace.hrx", "dst")
+		So(err, ShouldBeNil)
+		data, err = os.ReadFile(filepath.Join("outside", "target.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "original\n")
+		info, err := os.Lstat(filepath.Join("dst", "link.txt"))
+		So(err, ShouldBeNil)
+		So(info.Mode()&os.ModeSymlink, ShouldEqual, 0)
+		data, err = os.ReadFile(filepath.Join("dst", "link.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "replaced\n")
+
+		So(os.WriteFile("inner.hrx", []byte("<===> inner/file.txt\ninside\n"), 0660), ShouldBeNil)
+		err = Extract(nil, "inner.hrx", "dst")
+		So(err, ShouldBeNil)
+		data, err = os.ReadFile(filepath.Join("dst", "real", "file.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "inside\n")
+
+		err = Extract(&Options{UnsafePaths: true}, "escape.hrx", "dst")
+		So(err, ShouldBeNil)
+		data, err = os.ReadFile(filepath.Join("outside", "target.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "replaced\n")
+
+	})
+
+	Convey("Overwrite Policies", t, func() {
+		backupNotifier()
+		defer restoreNotifier()
+
+		so := stdio.NewStdout()
+		So(so.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer so.Restore()
+
+		tempdir, err := tdata.NewTempData("", "hrx.overwrite.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		_ = chdirs.Push(tempdir.Path())
+		defer func() { _ = chdirs.Pop() }()
+
+		So(os.WriteFile("files.hrx", []byte("<===> one.txt\narchived\n<===> two.txt\narchived\n"), 0660), ShouldBeNil)
+		past := time.Now().Add(-time.Hour)
+		So(os.Chtimes("files.hrx", past, past), ShouldBeNil)
+
+		reset := func() {
+			So(os.MkdirAll("out", 0770), ShouldBeNil)
+			So(os.WriteFile(filepath.Join("out", "one.txt"), []byte("existing\n"), 0660), ShouldBeNil)
+			older := past.Add(-time.Hour)
+			So(os.Chtimes(filepath.Join("out", "one.txt"), older, older), ShouldBeNil)
+			So(os.WriteFile(filepath.Join("out", "two.txt"), []byte("existing\n"), 0660), ShouldBeNil)
+		}
+		contents := func(name string) string {
+			data, _ := os.ReadFile(filepath.Join("out", name))
+			return string(data)
+		}
+
+		reset()
+		err = Extract(&Options{Overwrite: "never"}, "files.hrx", "out")
+		So(err, ShouldWrap, ErrInvalidOverwrite)
+
+		err = Extract(nil, "files.hrx", "out")
+		So(err, ShouldBeNil)
+		So(contents("one.txt"), ShouldEqual, "archived")
+		So(contents("two.txt"), ShouldEqual, "archived\n")
+
+		reset()
+		err = Extract(&Options{Overwrite: OverwriteKeep}, "files.hrx", "out")
+		So(err, ShouldBeNil)
+		So(contents("one.txt"), ShouldEqual, "existing\n")
+		So(contents("two.txt"), ShouldEqual, "existing\n")
+		So(string(so.Data()), ShouldContainSubstring, "kept | one.txt")
+
+		reset()
+		err = Extract(&Options{Overwrite: OverwriteSkipNewer}, "files.hrx", "out")
+		So(err, ShouldBeNil)
+		So(contents("one.txt"), ShouldEqual, "archived")
+		So(contents("two.txt"), ShouldEqual, "existing\n")
+		So(string(so.Data()), ShouldContainSubstring, "newer | two.txt")
+
+		reset()
+		err = Extract(&Options{Overwrite: OverwriteBackup}, "files.hrx", "out")
+		So(err, ShouldBeNil)
+		reset()
+		err = Extract(&Options{Overwrite: OverwriteBackup}, "files.hrx", "out")
+		So(err, ShouldBeNil)
+		So(contents("one.txt"), ShouldEqual, "archived")
+		So(contents("one.txt.~1~"), ShouldEqual, "existing\n")
+		So(contents("one.txt.~2~"), ShouldEqual, "existing\n")
+		So(clPath.Exists(filepath.Join("out", "one.txt.~3~")), ShouldBeFalse)
+		So(string(so.Data()), ShouldContainSubstring, "backed-up | one.txt")
+
+		// existing files beyond a symlinked directory are never touched
+		outside := tempdir.Join("outside")
+		So(os.MkdirAll(outside, 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(outside, "target.txt"), []byte("outside\n"), 0660), ShouldBeNil)
+		So(os.MkdirAll("linked", 0770), ShouldBeNil)
+		So(os.Symlink(outside, filepath.Join("linked", "out")), ShouldBeNil)
+		So(os.WriteFile("linked.hrx", []byte("<===> out/target.txt\narchived\n"), 0660), ShouldBeNil)
+		for _, policy := range []string{OverwriteBackup, OverwriteKeep, OverwriteSkipNewer} {
+			err = Extract(&Options{Overwrite: policy}, "linked.hrx", "linked")
+			So(err, ShouldWrap, ErrUnsafePath)
+			found, ee := clPath.ListFiles(outside, true)
+			So(ee, ShouldBeNil)
+			So(found, ShouldEqual, []string{filepath.Join(outside, "target.txt")})
+			data, _ := os.ReadFile(filepath.Join(outside, "target.txt"))
+			So(string(data), ShouldEqual, "outside\n")
+		}
+
+	})
+
+	Convey("Dry Run", t, func() {
+		backupNotifier()
+		defer restoreNotifier()
+
+		so := stdio.NewStdout()
+		So(so.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer so.Restore()
+
+		tempdir, err := tdata.NewTempData("", "hrx.dryrun.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		a, _, err := Create(
+			&Options{Recurse: true, PruneDir: true, DryRun: true},
+			tempdir.Join("created.hrx"),
+			"files-in-directories",
+		)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"dir/file1", "path/to/file2"})
+		So(clPath.Exists(tempdir.Join("created.hrx")), ShouldBeFalse)
+		var buf bytes.Buffer
+		So(writeArchive(&buf, a), ShouldBeNil)
+		So(string(so.Data()), ShouldContainSubstring, humanize.Bytes(uint64(buf.Len()))+" | created.hrx\n")
+
+		err = Extract(&Options{DryRun: true}, td.Join("simple.hrx"), tempdir.Join("simple.d"))
+		So(err, ShouldBeNil)
+		So(clPath.Exists(tempdir.Join("simple.d")), ShouldBeFalse)
+		sod := string(so.Data())
+		So(sod, ShouldContainSubstring, "65 B | input.scss")
+		So(sod, ShouldContainSubstring, "127 B | simple.d\n")
+
+		err = Extract(&Options{DryRun: true, PruneDir: true}, td.Join("files-in-directories.hrx"), tempdir.Join("fid.d"))
+		So(err, ShouldBeNil)
+		So(clPath.Exists(tempdir.Join("fid.d")), ShouldBeFalse)
+
+		So(os.MkdirAll(tempdir.Join("existing"), 0770), ShouldBeNil)
+		So(os.WriteFile(tempdir.Join("existing", "input.scss"), []byte("existing\n"), 0660), ShouldBeNil)
+		err = Extract(&Options{DryRun: true, Overwrite: OverwriteBackup}, td.Join("simple.hrx"), tempdir.Join("existing"))
+		So(err, ShouldBeNil)
+		So(string(so.Data()), ShouldContainSubstring, "backed-up | input.scss")
+		found, _ := clPath.ListAllFiles(tempdir.Join("existing"), true)
+		So(found, ShouldEqual, []string{tempdir.Join("existing", "input.scss")})
+
+		So(os.Symlink(tempdir.Path(), tempdir.Join("existing", "out")), ShouldBeNil)
+		So(os.WriteFile(tempdir.Join("escape.hrx"), []byte("<===> out/file.txt\nnope\n"), 0660), ShouldBeNil)
+		err = Extract(&Options{DryRun: true}, tempdir.Join("escape.hrx"), tempdir.Join("existing"))
+		So(err, ShouldWrap, ErrUnsafePath)
+
+		So(os.WriteFile(tempdir.Join("simple.hrx"), []byte(td.F("simple.hrx")), 0660), ShouldBeNil)
+		a, err = DeleteContext(context.Background(), &Options{DryRun: true}, tempdir.Join("simple.hrx"), "input.scss")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(a.List(), ShouldEqual, []string{"output.css"})
+		So(tempdir.F("simple.hrx"), ShouldEqual, td.F("simple.hrx"))
+		buf.Reset()
+		So(writeArchive(&buf, a), ShouldBeNil)
+		So(string(so.Data()), ShouldContainSubstring, humanize.Bytes(uint64(buf.Len()))+" | simple.hrx\n")
+
+	})
+
+	Convey("Structured Output", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.format.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		var buf bytes.Buffer
+		err = ListContext(context.Background(), &Options{Format: "yaml", Output: &buf}, td.Join("simple.hrx"))
+		So(err, ShouldWrap, ErrInvalidFormat)
+
+		err = ListContext(context.Background(), &Options{Format: FormatJSON, Output: &buf}, td.Join("simple.hrx"))
+		So(err, ShouldBeNil)
+		var records []*Record
+		So(json.Unmarshal(buf.Bytes(), &records), ShouldBeNil)
+		So(records, ShouldEqual, []*Record{
+			{Pathname: "input.scss", Type: "file", Size: 65, Operation: OpListing},
+			{Pathname: "output.css", Type: "file", Size: 62, Operation: OpListing},
+		})
+
+		buf.Reset()
+		a, _, err := Create(
+			&Options{Recurse: true, KeepEmpty: true, Format: FormatNDJSON, Output: &buf},
+			tempdir.Join("created.hrx"),
+			"files-in-directories",
+		)
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		So(lines, ShouldHaveLength, 3)
+		var record Record
+		So(json.Unmarshal([]byte(lines[0]), &record), ShouldBeNil)
+		So(record, ShouldEqual, Record{Pathname: "files-in-directories/dir/file1", Type: "file", Size: 91, Operation: hrx.OpAppended})
+		// the boundary used is the last record, as with the summary table
+		So(json.Unmarshal([]byte(lines[2]), &record), ShouldBeNil)
+		So(record, ShouldEqual, Record{Pathname: "<=====>", Type: "boundary", Size: 5, Operation: hrx.OpBoundary})
+
+		buf.Reset()
+		err = Extract(
+			&Options{Format: FormatCSV, Output: &buf},
+			td.Join("files-in-directories.hrx"),
+			tempdir.Join("fid.d"),
+		)
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, strings.Join([]string{
+			"pathname,type,size,comment,operation,destination,reason",
+			"dir/file1,file,91,,extracted," + tempdir.Join("fid.d", "dir", "file1") + ",",
+			"path/to/file2,file,36,,extracted," + tempdir.Join("fid.d", "path", "to", "file2") + ",",
+			"",
+		}, "\n"))
+
+	})
+
+	Convey("Concurrent Sessions", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.sessions.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		sources := []string{"simple", "files-in-directories"}
+		outputs := make([]*bytes.Buffer, len(sources))
+		options := make([]*Options, len(sources))
+		errs := make([]error, len(sources))
+		for idx := range sources {
+			outputs[idx] = &bytes.Buffer{}
+			options[idx] = &Options{
+				Recurse:  true,
+				Notifier: notify.New(notify.Info).SetOut(outputs[idx]).Make(),
+			}
+		}
+
+		var wg sync.WaitGroup
+		for idx, source := range sources {
+			wg.Add(1)
+			go func(idx int, source string) {
+				defer wg.Done()
+				for count := 0; count < 10; count++ {
+					outputs[idx].Reset()
+					if _, _, errs[idx] = Create(options[idx], tempdir.Join(source+".hrx"), source); errs[idx] != nil {
+						return
+					}
+				}
+			}(idx, source)
+		}
+		wg.Wait()
+
+		for idx, source := range sources {
+			So(errs[idx], ShouldBeNil)
+			So(options[idx].Boundary, ShouldEqual, 0)
+			for _, other := range sources {
+				if other != source {
+					So(outputs[idx].String(), ShouldNotContainSubstring, " "+other+"/")
+				}
+			}
+			So(outputs[idx].String(), ShouldContainSubstring, " "+source+"/")
+			So(strings.Count(outputs[idx].String(), "ARCHIVED"), ShouldEqual, 1)
+		}
+
+	})
+
+	Convey("Cancellation", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.context.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		cancelled, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = ListContext(cancelled, nil, td.Join("simple.hrx"))
+		So(err, ShouldWrap, context.Canceled)
+		So(err.Error(), ShouldContainSubstring, `"input.scss"`)
+
+		a, skipped, err := CreateContext(cancelled, nil, tempdir.Join("cancelled.hrx"), td.Join("simple"))
+		So(err, ShouldWrap, context.Canceled)
+		So(a, ShouldBeNil)
+		So(skipped, ShouldBeNil)
+		So(clPath.Exists(tempdir.Join("cancelled.hrx")), ShouldBeFalse)
+
+		deadline, stop := context.WithTimeout(context.Background(), 0)
+		defer stop()
+		_, _, err = CreateContext(deadline, nil, tempdir.Join("expired.hrx"), td.Join("simple"))
+		So(err, ShouldWrap, context.DeadlineExceeded)
+
+		// cancelled after the first file is extracted
+		err = ExtractContext(newCancelAfter(1), nil, td.Join("files-in-directories.hrx"), tempdir.Join("fid.d"))
+		So(err, ShouldWrap, context.Canceled)
+		So(err.Error(), ShouldContainSubstring, `"path/to/file2"`)
+		So(clPath.Exists(tempdir.Join("fid.d")), ShouldBeFalse)
+
+		// existing files are left and backups are restored
+		So(os.MkdirAll(tempdir.Join("kept.d", "dir"), 0770), ShouldBeNil)
+		So(os.WriteFile(tempdir.Join("kept.d", "dir", "file1"), []byte("original"), 0660), ShouldBeNil)
+		err = ExtractContext(newCancelAfter(1), &Options{Overwrite: OverwriteBackup}, td.Join("files-in-directories.hrx"), tempdir.Join("kept.d"))
+		So(err, ShouldWrap, context.Canceled)
+		var found []string
+		found, err = clPath.ListAllFiles(tempdir.Join("kept.d"), true)
+		So(err, ShouldBeNil)
+		So(found, ShouldEqual, []string{tempdir.Join("kept.d", "dir", "file1")})
+		data, err := os.ReadFile(tempdir.Join("kept.d", "dir", "file1"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "original")
+
+		err = ExtractContext(context.Background(), nil, td.Join("files-in-directories.hrx"), tempdir.Join("fid.d"))
+		So(err, ShouldBeNil)
+		So(clPath.IsFile(tempdir.Join("fid.d", "path", "to", "file2")), ShouldBeTrue)
+
+		err = ListFromContext(cancelled, nil, strings.NewReader(td.F("simple.hrx")))
+		So(err, ShouldWrap, context.Canceled)
+
+		var buf bytes.Buffer
+		_, _, err = CreateToContext(cancelled, nil, &buf, td.Join("simple"))
+		So(err, ShouldWrap, context.Canceled)
+		_, err = CreateStreamToContext(cancelled, nil, &buf, td.Join("simple"))
+		So(err, ShouldWrap, context.Canceled)
+		So(buf.Len(), ShouldEqual, 0)
+
+		err = ExtractFromContext(cancelled, nil, strings.NewReader(td.F("files-in-directories.hrx")), tempdir.Join("from.d"))
+		So(err, ShouldWrap, context.Canceled)
+		So(clPath.Exists(tempdir.Join("from.d")), ShouldBeFalse)
+
+		// existing archives are left as-is
+		original := td.F("simple.hrx")
+		So(os.WriteFile(tempdir.Join("simple.hrx"), []byte(original), 0660), ShouldBeNil)
+		_, err = AppendContext(cancelled, nil, tempdir.Join("simple.hrx"), td.Join("simple"))
+		So(err, ShouldWrap, context.Canceled)
+		_, err = UpdateContext(cancelled, nil, tempdir.Join("simple.hrx"), td.Join("simple"))
+		So(err, ShouldWrap, context.Canceled)
+		_, err = DeleteContext(cancelled, nil, tempdir.Join("simple.hrx"), "input.scss")
+		So(err, ShouldWrap, context.Canceled)
+		So(tempdir.F("simple.hrx"), ShouldEqual, original)
+
+		_, err = DiffContext(cancelled, nil, tempdir.Join("simple.hrx"), td.Join("simple"))
+		So(err, ShouldWrap, context.Canceled)
+
+	})
+
+	Convey("Create From FS", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.fromfs.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		fsys := fstest.MapFS{
+			"src/.hidden":        {Data: []byte("hidden\n")},
+			"src/.hrxignore":     {Data: []byte("*.log\n")},
+			"src/b/file10.txt":   {Data: []byte("ten\n")},
+			"src/b/file2.txt":    {Data: []byte("two\n")},
+			"src/a/nested/c.txt": {Data: []byte("c\n")},
+			"src/debug.log":      {Data: []byte("ignored\n")},
+			"src/empty":          {Mode: fs.ModeDir | 0770},
+			"src/image.bin":      {Data: []byte{0, 1, 2}},
+			"src/top.txt":        {Data: []byte("top\n")},
+		}
+
+		_, _, err = CreateFromFS(nil, fsys, tempdir.Join("none.hrx"))
+		So(err, ShouldEqual, ErrPathRequired)
+		_, _, err = CreateFromFS(nil, fsys, tempdir.Join("none.hrx"), "nope")
+		So(err, ShouldWrap, fs.ErrNotExist)
+
+		// the same tree on disk is archived identically
+		for name, file := range fsys {
+			if file.Mode.IsDir() {
+				So(os.MkdirAll(tempdir.Join("disk", name), 0770), ShouldBeNil)
+				continue
+			}
+			So(os.MkdirAll(filepath.Dir(tempdir.Join("disk", name)), 0770), ShouldBeNil)
+			So(os.WriteFile(tempdir.Join("disk", name), file.Data, 0660), ShouldBeNil)
+		}
+		pushed := chdirs.Push(tempdir.Join("disk"))
+		So(pushed, ShouldBeNil)
+		opt := &Options{Recurse: true, All: true, KeepEmpty: true, Binary: BinaryBase64}
+		_, _, err = Create(opt, tempdir.Join("disk.hrx"), "src")
+		So(chdirs.Pop(), ShouldBeNil)
+		So(err, ShouldBeNil)
+
+		a, skipped, err := CreateFromFS(opt, fsys, tempdir.Join("fsys.hrx"), "src")
+		So(err, ShouldBeNil)
+		So(a, ShouldNotBeNil)
+		So(skipped, ShouldEqual, []*Skipped{{Pathname: "src/debug.log", Source: "src/debug.log", Err: ErrIgnored}})
+		So(a.List(), ShouldEqual, []string{
+			"src/a/nested/c.txt", "src/b/file2.txt", "src/b/file10.txt",
+			"src/.hidden", "src/.hrxignore", "src/image.bin", "src/top.txt",
+		})
+		expected, err := os.ReadFile(tempdir.Join("disk.hrx"))
+		So(err, ShouldBeNil)
+		actual, err := os.ReadFile(tempdir.Join("fsys.hrx"))
+		So(err, ShouldBeNil)
+		So(string(actual), ShouldEqual, string(expected))
+
+		a, _, err = CreateFromFS(&Options{PruneDir: true}, fsys, tempdir.Join("pruned.hrx"), "src/b", "src/top.txt")
+		So(err, ShouldBeNil)
+		So(a.List(), ShouldEqual, []string{"b/file2.txt", "b/file10.txt", "top.txt"})
+
+	})
+
+	Convey("Parallel Reading", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.workers.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		pushed := chdirs.Push(tempdir.Path())
+		So(pushed, ShouldBeNil)
+		defer func() { _ = chdirs.Pop() }()
+
+		for idx := 0; idx < 40; idx++ {
+			name := filepath.Join("src", "dir"+strconv.Itoa(idx%4), "file"+strconv.Itoa(idx)+".txt")
+			So(os.MkdirAll(filepath.Dir(name), 0770), ShouldBeNil)
+			So(os.WriteFile(name, []byte(strings.Repeat("line "+strconv.Itoa(idx)+"\n", idx+1)), 0660), ShouldBeNil)
+		}
+		So(os.WriteFile(filepath.Join("src", "dir1", "image.bin"), []byte{0xff, 0xfe, 0}, 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "dir2", "large.txt"), []byte(strings.Repeat("x", 1024)), 0660), ShouldBeNil)
+
+		_, serial, err := Create(&Options{Recurse: true, MaxSize: 512}, "serial.hrx", "src")
+		So(err, ShouldBeNil)
+		_, parallel, err := Create(&Options{Recurse: true, MaxSize: 512, Workers: 8}, "parallel.hrx", "src")
+		So(err, ShouldBeNil)
+		So(parallel, ShouldEqual, serial)
+		So(parallel, ShouldHaveLength, 2)
+		So(parallel[0].Source, ShouldEqual, filepath.Join("src", "dir1", "image.bin"))
+		So(parallel[1].Source, ShouldEqual, filepath.Join("src", "dir2", "large.txt"))
+
+		expected, err := os.ReadFile("serial.hrx")
+		So(err, ShouldBeNil)
+		actual, err := os.ReadFile("parallel.hrx")
+		So(err, ShouldBeNil)
+		So(string(actual), ShouldEqual, string(expected))
+
+		_, _, err = Create(&Options{Recurse: true, Binary: BinaryError, Workers: 8}, "binary.hrx", "src")
+		So(err, ShouldWrap, ErrNotPlainText)
+		So(err.Error(), ShouldContainSubstring, "image.bin")
+		_, _, err = Create(&Options{Recurse: true, Strict: true, MaxSize: 512, Binary: BinaryBase64, Workers: 8}, "strict.hrx", "src")
+		So(err, ShouldWrap, ErrTooLarge)
+		So(err.Error(), ShouldContainSubstring, "large.txt")
+
+	})
+
+	Convey("Streaming", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.stream.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		pushed := chdirs.Push(tempdir.Path())
+		So(pushed, ShouldBeNil)
+		defer func() { _ = chdirs.Pop() }()
+
+		for idx := 0; idx < 12; idx++ {
+			name := filepath.Join("src", "dir"+strconv.Itoa(idx%3), "file"+strconv.Itoa(idx)+".txt")
+			So(os.MkdirAll(filepath.Dir(name), 0770), ShouldBeNil)
+			So(os.WriteFile(name, []byte(strings.Repeat("line "+strconv.Itoa(idx)+"\n", idx+1)), 0660), ShouldBeNil)
+		}
+		So(os.MkdirAll(filepath.Join("src", "empty"), 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "dir1", "image.bin"), []byte{0xff, 0xfe, 0}, 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "top.txt"), []byte("no trailing newline"), 0660), ShouldBeNil)
+		nested := "<=====> inner.txt\ninner\n<=====> inner/\n"
+		So(os.WriteFile(filepath.Join("src", "dir2", "nested.hrx"), []byte(nested), 0660), ShouldBeNil)
+
+		for _, opt := range []*Options{
+			{Recurse: true},
+			{Recurse: true, KeepEmpty: true, Boundary: 3},
+			{Recurse: true, Binary: BinaryBase64, PruneDir: true},
+		} {
+			var created, streamed bytes.Buffer
+			opt.Format = FormatNDJSON
+			opt.Output = &created
+			a, expected, err := Create(opt, "created.hrx", "src", filepath.Join("src", "top.txt"))
+			So(err, ShouldBeNil)
+			name := "src/dir2/nested.hrx"
+			if opt.PruneDir {
+				name = "dir2/nested.hrx"
+			}
+			body, _, ok := a.Get(name)
+			So(ok, ShouldBeTrue)
+			So(body, ShouldEqual, nested)
+			opt.Output = &streamed
+			skipped, err := CreateStream(opt, "streamed.hrx", "src", filepath.Join("src", "top.txt"))
+			So(err, ShouldBeNil)
+			So(skipped, ShouldEqual, expected)
+			So(streamed.String(), ShouldEqual, created.String())
+
+			wanted, err := os.ReadFile("created.hrx")
+			So(err, ShouldBeNil)
+			data, err := os.ReadFile("streamed.hrx")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, string(wanted))
+
+			var buf bytes.Buffer
+			opt.Output = io.Discard
+			_, err = CreateStreamTo(opt, &buf, "src", filepath.Join("src", "top.txt"))
+			So(err, ShouldBeNil)
+			So(buf.String(), ShouldEqual, string(wanted))
+		}
+
+		skipped, err := CreateStream(&Options{Recurse: true, DryRun: true}, "dry.hrx", "src")
+		So(err, ShouldBeNil)
+		So(skipped, ShouldHaveLength, 1)
+		So(clPath.Exists("dry.hrx"), ShouldBeFalse)
+
+		_, err = CreateStream(&Options{Recurse: true, Binary: BinaryError}, "streamed.hrx", "src")
+		So(err, ShouldWrap, ErrNotPlainText)
+		found, err := clPath.ListFiles(".", true)
+		So(err, ShouldBeNil)
+		So(found, ShouldEqual, []string{"created.hrx", "streamed.hrx"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = CreateStreamContext(ctx, nil, "cancelled.hrx", "src")
+		So(err, ShouldWrap, context.Canceled)
+		So(clPath.Exists("cancelled.hrx"), ShouldBeFalse)
+
+		_, err = CreateStream(nil, "streamed.hrx")
+		So(err, ShouldEqual, ErrPathRequired)
+
+	})
+
+	Convey("Automatic Boundary", t, func() {
+
+		defer restoreNotifier()
+
+		so := stdio.NewStdout()
+		So(so.Capture(), ShouldBeNil)
+		Notifier = notify.New(notify.Info).Make()
+		defer so.Restore()
+
+		tempdir, err := tdata.NewTempData("", "hrx.boundary.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		pushed := chdirs.Push(tempdir.Path())
+		So(pushed, ShouldBeNil)
+		defer func() { _ = chdirs.Pop() }()
+
+		// nested archives may have lines that look like a boundary
+		nested := "<=====> inner.hrx\n<======> deep.txt\ndeep\n"
+		So(os.MkdirAll("src", 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "nested.hrx"), []byte(nested), 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "plain.txt"), []byte("plain <=====> text\n"), 0660), ShouldBeNil)
+
+		a, _, err := Create(nil, "auto.hrx", "src")
+		So(err, ShouldBeNil)
+		So(a.GetBoundary(), ShouldEqual, 7)
+		So(string(so.Data()), ShouldContainSubstring, "boundary | <=======> (7)")
+		So(tempdir.F("auto.hrx"), ShouldStartWith, "<=======> src/nested.hrx\n"+nested+"\n<=======> src/plain.txt\n")
+
+		// the archive written reads back the same
+		var buf bytes.Buffer
+		err = ListContext(context.Background(), &Options{Format: FormatCSV, Output: &buf}, "auto.hrx")
+		So(err, ShouldBeNil)
+		So(strings.Count(buf.String(), "\n"), ShouldEqual, 3)
+		So(buf.String(), ShouldContainSubstring, "\nsrc/nested.hrx,")
+		So(buf.String(), ShouldContainSubstring, "\nsrc/plain.txt,")
+		So(Extract(nil, "auto.hrx", "out"), ShouldBeNil)
+		found, err := clPath.ListAllFiles("out", true)
+		So(err, ShouldBeNil)
+		So(found, ShouldEqual, []string{filepath.Join("out", "src", "nested.hrx"), filepath.Join("out", "src", "plain.txt")})
+		So(tempdir.F(filepath.Join("out", "src", "nested.hrx")), ShouldEqual, nested)
+		So(tempdir.F(filepath.Join("out", "src", "plain.txt")), ShouldEqual, "plain <=====> text\n")
+
+		_, err = CreateStream(nil, "streamed.hrx", "src")
+		So(err, ShouldBeNil)
+		So(tempdir.F("streamed.hrx"), ShouldEqual, tempdir.F("auto.hrx"))
+
+		So(so.Reset(), ShouldBeNil)
+		a, _, err = Create(&Options{Recurse: true, Boundary: 3}, "fixed.hrx", "src")
+		So(err, ShouldBeNil)
+		So(a.GetBoundary(), ShouldEqual, 3)
+		So(string(so.Data()), ShouldContainSubstring, "boundary | <===> (3)")
+
+		a, _, err = Create(nil, "plain.hrx", filepath.Join("src", "plain.txt"))
+		So(err, ShouldBeNil)
+		So(a.GetBoundary(), ShouldEqual, hrx.DefaultBoundary)
+
+		// any other lines that look like a boundary, of any size, are refused
+		// regardless of the boundary, unless encoded
+		conflicts := "<===> not an entry\n<======>\nnot a comment either\n"
+		So(os.MkdirAll("bad", 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("bad", "conflicts.txt"), []byte(conflicts), 0660), ShouldBeNil)
+		for _, opt := range []*Options{nil, {Recurse: true, Boundary: 3}, {Recurse: true, Boundary: hrx.DefaultBoundary}} {
+			_, _, err = Create(opt, "bad.hrx", "bad")
+			So(err, ShouldWrap, ErrBoundaryCollision)
+			So(err.Error(), ShouldContainSubstring, `"bad/conflicts.txt"`)
+			_, err = CreateStream(opt, "bad.hrx", "bad")
+			So(err, ShouldWrap, ErrBoundaryCollision)
+			So(clPath.Exists("bad.hrx"), ShouldBeFalse)
+			buf.Reset()
+			_, _, err = CreateTo(opt, &buf, "bad")
+			So(err, ShouldWrap, ErrBoundaryCollision)
+			_, err = CreateStreamTo(opt, &buf, "bad")
+			So(err, ShouldWrap, ErrBoundaryCollision)
+			So(buf.Len(), ShouldEqual, 0)
+		}
+		_, _, err = Create(&Options{Recurse: true, Boundary: hrx.DefaultBoundary}, "fixed.hrx", "src")
+		So(err, ShouldWrap, ErrBoundaryCollision)
+
+		a, _, err = Create(&Options{Recurse: true, Binary: BinaryBase64}, "encoded.hrx", "bad")
+		So(err, ShouldBeNil)
+		_, comment, _ := a.Get("bad/conflicts.txt")
+		So(comment, ShouldEqual, EncodingBase64)
+		So(Extract(nil, "encoded.hrx", "decoded"), ShouldBeNil)
+		So(tempdir.F(filepath.Join("decoded", "bad", "conflicts.txt")), ShouldEqual, conflicts)
+		_, err = CreateStream(&Options{Recurse: true, Binary: BinaryBase64}, "encoded-streamed.hrx", "bad")
+		So(err, ShouldBeNil)
+		So(tempdir.F("encoded-streamed.hrx"), ShouldEqual, tempdir.F("encoded.hrx"))
+
+		So(os.WriteFile("a.txt", []byte("a\n"), 0660), ShouldBeNil)
+		So(os.WriteFile("b.txt", []byte("b\n<=====> injected.txt\n"), 0660), ShouldBeNil)
+		a, _, err = Create(nil, "appended.hrx", "a.txt")
+		So(err, ShouldBeNil)
+		So(a.GetBoundary(), ShouldEqual, hrx.DefaultBoundary)
+		original := tempdir.F("appended.hrx")
+		for _, opt := range []*Options{nil, {Boundary: hrx.DefaultBoundary}} {
+			a, err = Append(opt, "appended.hrx", "b.txt")
+			So(err, ShouldWrap, ErrBoundaryCollision)
+			So(a, ShouldBeNil)
+			a, err = Update(opt, "appended.hrx", "b.txt")
+			So(err, ShouldWrap, ErrBoundaryCollision)
+			So(a, ShouldBeNil)
+			So(tempdir.F("appended.hrx"), ShouldEqual, original)
+		}
+		a, err = Append(nil, "appended.hrx", filepath.Join("src", "plain.txt"))
+		So(err, ShouldBeNil)
+		So(a.GetBoundary(), ShouldEqual, hrx.DefaultBoundary)
+		So(a.List(), ShouldEqual, []string{"a.txt", "src/plain.txt"})
+
+		// a nested archive colliding with the boundary needs a larger one
+		a, err = Append(&Options{Boundary: hrx.DefaultBoundary}, "appended.hrx", filepath.Join("src", "nested.hrx"))
+		So(err, ShouldWrap, ErrBoundaryCollision)
+		So(a, ShouldBeNil)
+		a, err = Append(nil, "appended.hrx", filepath.Join("src", "nested.hrx"))
+		So(err, ShouldBeNil)
+		So(a.GetBoundary(), ShouldEqual, 7)
+		parsed, err := hrx.ParseFile("appended.hrx")
+		So(err, ShouldBeNil)
+		So(parsed.GetBoundary(), ShouldEqual, 7)
+		So(parsed.List(), ShouldEqual, []string{"a.txt", "src/plain.txt", "src/nested.hrx"})
+		body, _, _ := parsed.Get("src/nested.hrx")
+		So(body, ShouldEqual, nested)
+
+	})
+
+	Convey("Sorting", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.sort.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		pushed := chdirs.Push(tempdir.Path())
+		So(pushed, ShouldBeNil)
+		defer func() { _ = chdirs.Pop() }()
+
+		now := time.Now()
+		for idx, name := range []string{"b/file10.txt", "b/file2.txt", "a/file1.txt", "c.txt"} {
+			So(os.MkdirAll(filepath.Join("src", filepath.Dir(name)), 0770), ShouldBeNil)
+			So(os.WriteFile(filepath.Join("src", name), []byte(strings.Repeat("x", 10-idx)+"\n"), 0660), ShouldBeNil)
+			modTime := now.Add(time.Duration(idx) * time.Minute)
+			So(os.Chtimes(filepath.Join("src", name), modTime, modTime), ShouldBeNil)
+		}
+
+		a, _, err := Create(&Options{Recurse: true}, "none.hrx", "src")
+		So(err, ShouldBeNil)
+		So(a.List(), ShouldEqual, []string{"src/a/file1.txt", "src/b/file2.txt", "src/b/file10.txt", "src/c.txt"})
+
+		for order, expected := range map[string][]string{
+			SortName:    {"src/a/file1.txt", "src/b/file10.txt", "src/b/file2.txt", "src/c.txt"},
+			SortNatural: {"src/a/file1.txt", "src/b/file2.txt", "src/b/file10.txt", "src/c.txt"},
+			SortMtime:   {"src/b/file10.txt", "src/b/file2.txt", "src/a/file1.txt", "src/c.txt"},
+			SortSize:    {"src/c.txt", "src/a/file1.txt", "src/b/file2.txt", "src/b/file10.txt"},
+		} {
+			opt := &Options{Recurse: true, Sort: order, Workers: 4}
+			a, _, err = Create(opt, order+".hrx", "src")
+			So(err, ShouldBeNil)
+			So(a.List(), ShouldEqual, expected)
+
+			// the same archive regardless of the order of the pathnames
+			reversed := []string{filepath.Join("src", "c.txt"), filepath.Join("src", "b"), filepath.Join("src", "a")}
+			_, _, err = Create(&Options{Recurse: true, Sort: order}, "reversed.hrx", reversed...)
+			So(err, ShouldBeNil)
+			_, err = CreateStream(&Options{Recurse: true, Sort: order}, "streamed.hrx", reversed...)
+			So(err, ShouldBeNil)
+			So(tempdir.F("reversed.hrx"), ShouldEqual, tempdir.F(order+".hrx"))
+			So(tempdir.F("streamed.hrx"), ShouldEqual, tempdir.F(order+".hrx"))
+		}
+
+		var buf bytes.Buffer
+		err = ListContext(context.Background(), &Options{Sort: SortSize, Format: FormatCSV, Output: &buf}, "none.hrx")
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, strings.Join([]string{
+			"pathname,type,size,comment,operation,destination,reason",
+			"src/c.txt,file,8,,listing,,",
+			"src/a/file1.txt,file,9,,listing,,",
+			"src/b/file2.txt,file,10,,listing,,",
+			"src/b/file10.txt,file,11,,listing,,",
+			"",
+		}, "\n"))
+
+		_, _, err = Create(&Options{Sort: "random"}, "random.hrx", "src")
+		So(err, ShouldWrap, ErrInvalidSort)
+		So(ListContext(context.Background(), &Options{Sort: "random"}, "none.hrx"), ShouldWrap, ErrInvalidSort)
+
+	})
+
+	Convey("Comments", t, func() {
+
+		tempdir, err := tdata.NewTempData("", "hrx.comments.*")
+		So(err, ShouldBeNil)
+		So(tempdir, ShouldNotBeNil)
+		defer tempdir.Destroy()
+
+		pushed := chdirs.Push(tempdir.Path())
+		So(pushed, ShouldBeNil)
+		defer func() { _ = chdirs.Pop() }()
+
+		So(os.MkdirAll(filepath.Join("src", "docs"), 0770), ShouldBeNil)
+		So(os.MkdirAll(filepath.Join("src", "empty"), 0770), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "docs", "readme.txt"), []byte("read me\n"), 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "notes.txt"), []byte("notes\n"), 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join("src", "image.bin"), []byte{0xff, 0xfe, 0}, 0660), ShouldBeNil)
+		So(os.WriteFile("comment.txt", []byte("a longer comment\n<=====> looks like a boundary\n"), 0660), ShouldBeNil)
+
+		opt := &Options{
+			Recurse:       true,
+			KeepEmpty:     true,
+			Binary:        BinaryBase64,
+			Comment:       "the archive",
+			EntryComments: []string{"*.txt=text, plain", "**/docs/*=documentation", "empty=nothing here", "*.bin=binary"},
+		}
+		// nested empty directories are only kept when given
+		args := []string{"src", filepath.Join("src", "empty")}
+		a, _, err := Create(opt, "created.hrx", args...)
+		So(err, ShouldBeNil)
+		comment, ok := a.GetComment()
+		So(ok, ShouldBeTrue)
+		So(comment, ShouldEqual, "the archive")
+		_, comment, _ = a.Get("src/docs/readme.txt")
+		So(comment, ShouldEqual, "documentation")
+		_, comment, _ = a.Get("src/notes.txt")
+		So(comment, ShouldEqual, "text, plain")
+		_, comment, _ = a.Get("src/empty/")
+		So(comment, ShouldEqual, "nothing here")
+		_, comment, _ = a.Get("src/image.bin")
+		So(comment, ShouldEqual, EncodingBase64+"\nbinary")
+		So(tempdir.F("created.hrx"), ShouldEndWith, "\n<=====>\nthe archive")
+
+		var buf bytes.Buffer
+		_, _, err = CreateTo(opt, &buf, args...)
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, tempdir.F("created.hrx"))
+		_, err = CreateStream(opt, "streamed.hrx", args...)
+		So(err, ShouldBeNil)
+		So(tempdir.F("streamed.hrx"), ShouldEqual, tempdir.F("created.hrx"))
+
+		parsed, err := hrx.ParseFile("created.hrx")
+		So(err, ShouldBeNil)
+		comment, _ = parsed.GetComment()
+		So(comment, ShouldEqual, "the archive")
+
+		fileOpt := &Options{Recurse: true, CommentFile: "comment.txt"}
+		// a comment line that looks like a boundary would start a new entry
+		a, _, err = Create(fileOpt, "file.hrx", filepath.Join("src", "notes.txt"))
+		So(err, ShouldWrap, ErrBoundaryCollision)
+		So(a, ShouldBeNil)
+		_, err = CreateStream(fileOpt, "file-streamed.hrx", filepath.Join("src", "notes.txt"))
+		So(err, ShouldWrap, ErrBoundaryCollision)
+		So(clPath.Exists("file.hrx"), ShouldBeFalse)
+		So(clPath.Exists("file-streamed.hrx"), ShouldBeFalse)
+
+		// unchanged files are updated when the entry comment changes
+		a, err = Update(&Options{Comment: "updated", EntryComments: []string{"notes.txt=changed"}}, "created.hrx", filepath.Join("src", "notes.txt"), filepath.Join("src", "docs"))
+		So(err, ShouldBeNil)
+		comment, _ = a.GetComment()
+		So(comment, ShouldEqual, "updated")
+		_, comment, _ = a.Get("src/notes.txt")
+		So(comment, ShouldEqual, "changed")
+		// comments parsed from an archive end with a newline
+		_, comment, _ = a.Get("src/docs/readme.txt")
+		So(comment, ShouldEqual, "documentation\n")
+
+		var records bytes.Buffer
+		_, err = Update(&Options{Format: FormatCSV, Output: &records, EntryComments: []string{"notes.txt=changed"}}, "created.hrx", filepath.Join("src", "notes.txt"))
+		So(err, ShouldBeNil)
+		So(records.String(), ShouldNotContainSubstring, "notes.txt")
+
+		_, _, err = Create(&Options{Comment: "one", CommentFile: "comment.txt"}, "both.hrx", "src")
+		So(err, ShouldWrap, ErrInvalidComment)
+		_, _, err = Create(&Options{EntryComments: []string{"no text"}}, "bad.hrx", "src")
+		So(err, ShouldWrap, ErrInvalidComment)
+		_, _, err = Create(&Options{CommentFile: "nope.txt"}, "missing.hrx", "src")
+		So(err, ShouldWrap, ErrFileNotFound)
+		_, err = Append(&Options{CommentFile: "nope.txt"}, "created.hrx", "src")
+		So(err, ShouldWrap, ErrFileNotFound)
+		So(clPath.Exists("both.hrx") || clPath.Exists("bad.hrx") || clPath.Exists("missing.hrx"), ShouldBeFalse)
+
+	})
+
 	Convey("Extract", t, func() {
 
 		tempdir, err := tdata.NewTempData("", "hrx.extract.*")
@@ -0,0 +1,116 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrxfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/go-corelibs/hrx"
+)
+
+// fileInfo is the fs.FileInfo and the fs.DirEntry info of a node
+type fileInfo struct {
+	node    *node
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string {
+	return path.Base(fi.node.name)
+}
+
+func (fi *fileInfo) Size() int64 {
+	return int64(len(fi.node.data))
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.node.dir {
+		return fs.ModeDir | 0770
+	}
+	return hrx.DefaultFileMode
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.node.dir
+}
+
+// Sys returns the archive entry comment, which is empty for entries without
+// a comment and for implied directories
+func (fi *fileInfo) Sys() any {
+	return fi.node.comment
+}
+
+// openFile is an open file node
+type openFile struct {
+	fsys *FS
+	node *node
+	*bytes.Reader
+}
+
+func newOpenFile(fsys *FS, n *node) (file *openFile) {
+	return &openFile{fsys: fsys, node: n, Reader: bytes.NewReader(n.data)}
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) {
+	return f.fsys.info(f.node), nil
+}
+
+func (f *openFile) Close() error {
+	return nil
+}
+
+// openDir is an open directory node
+type openDir struct {
+	fsys   *FS
+	node   *node
+	offset int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) {
+	return d.fsys.info(d.node), nil
+}
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: fs.ErrInvalid}
+}
+
+func (d *openDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *openDir) ReadDir(count int) (entries []fs.DirEntry, err error) {
+	remaining := d.node.children[d.offset:]
+	if count > 0 {
+		if len(remaining) == 0 {
+			return nil, io.EOF
+		} else if count < len(remaining) {
+			remaining = remaining[:count]
+		}
+	}
+	entries = make([]fs.DirEntry, 0, len(remaining))
+	for _, child := range remaining {
+		entries = append(entries, fs.FileInfoToDirEntry(d.fsys.info(d.fsys.nodes[child])))
+	}
+	d.offset += len(remaining)
+	return
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hrxfs provides a read-only io/fs.FS view of an hrx archive
+package hrxfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-corelibs/hrx"
+
+	hrxutil "github.com/go-coreutils/hrx"
+)
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.GlobFS     = (*FS)(nil)
+)
+
+// FS is a read-only fs.FS of the file and directory entries within an
+// archive. Directories that are only implied by the pathnames of other
+// entries are present as well and the comment of each entry is available
+// as the string returned by the FileInfo.Sys method
+type FS struct {
+	nodes   map[string]*node
+	modTime time.Time
+}
+
+// node is a single file or directory within the FS
+type node struct {
+	name     string
+	dir      bool
+	data     []byte
+	comment  string
+	children []string
+}
+
+// Open parses the existing `src` archive file and returns the FS for it,
+// using the modification time of `src` for all the files and directories
+func Open(src string) (fsys *FS, err error) {
+	var a hrx.Archive
+	if a, err = hrxutil.Open(src); err != nil {
+		return
+	} else if fsys, err = New(a); err != nil {
+		return nil, err
+	}
+	if info, ee := os.Stat(src); ee == nil {
+		fsys.modTime = info.ModTime()
+	}
+	return
+}
+
+// New returns the FS for the archive given. Any base64 encoded entries are
+// decoded and entries with pathnames that are not valid fs.FS names, such as
+// those with ".." components, are left out
+func New(a hrx.Archive) (fsys *FS, err error) {
+	fsys = &FS{nodes: map[string]*node{".": {name: ".", dir: true}}}
+
+	for _, entry := range a.Entries() {
+		if entry.IsComment() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.GetPathname(), "/")
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+
+		n := fsys.add(name, entry.IsDir())
+		n.comment = entry.GetComment()
+		if entry.IsFile() {
+			if n.data, err = hrxutil.ReadEntry(entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, n := range fsys.nodes {
+		sort.Strings(n.children)
+	}
+	return
+}
+
+// add returns the node for `name`, creating it and any implied parent
+// directories as needed
+func (f *FS) add(name string, dir bool) (n *node) {
+	if n = f.nodes[name]; n != nil {
+		n.dir = n.dir || dir
+		return
+	}
+	n = &node{name: name, dir: dir}
+	f.nodes[name] = n
+	parent := f.add(path.Dir(name), true)
+	parent.children = append(parent.children, name)
+	return
+}
+
+// lookup returns the node for `name`, or an fs.PathError for the `op` given
+func (f *FS) lookup(op, name string) (n *node, err error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	} else if n = f.nodes[name]; n == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return
+}
+
+// Open implements fs.FS
+func (f *FS) Open(name string) (file fs.File, err error) {
+	var n *node
+	if n, err = f.lookup("open", name); err != nil {
+		return
+	} else if n.dir {
+		return &openDir{fsys: f, node: n}, nil
+	}
+	return newOpenFile(f, n), nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (f *FS) ReadDir(name string) (entries []fs.DirEntry, err error) {
+	var n *node
+	if n, err = f.lookup("readdir", name); err != nil {
+		return
+	} else if !n.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: hrxutil.ErrNotDirectory}
+	}
+	entries = make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(f.info(f.nodes[child])))
+	}
+	return
+}
+
+// ReadFile implements fs.ReadFileFS
+func (f *FS) ReadFile(name string) (data []byte, err error) {
+	var n *node
+	if n, err = f.lookup("readfile", name); err != nil {
+		return
+	} else if n.dir {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	data = make([]byte, len(n.data))
+	copy(data, n.data)
+	return
+}
+
+// Stat implements fs.StatFS
+func (f *FS) Stat(name string) (info fs.FileInfo, err error) {
+	var n *node
+	if n, err = f.lookup("stat", name); err != nil {
+		return
+	}
+	return f.info(n), nil
+}
+
+// Glob implements fs.GlobFS
+func (f *FS) Glob(pattern string) (matches []string, err error) {
+	// check the pattern is valid, even when nothing matches
+	if _, err = path.Match(pattern, ""); err != nil {
+		return
+	}
+	for name := range f.nodes {
+		if name == "." && pattern != "." {
+			// the root is only matched by name
+			continue
+		} else if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return
+}
+
+func (f *FS) info(n *node) (info *fileInfo) {
+	return &fileInfo{node: n, modTime: f.modTime}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrxfs
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/hrx"
+
+	hrxutil "github.com/go-coreutils/hrx"
+)
+
+func Test(t *testing.T) {
+
+	testdata, _ := filepath.Abs("../testdata")
+
+	Convey("Open", t, func() {
+
+		fsys, err := Open(filepath.Join(testdata, "nope.hrx"))
+		So(err, ShouldWrap, hrxutil.ErrFileNotFound)
+		So(fsys, ShouldBeNil)
+
+		fsys, err = Open(filepath.Join(testdata, "files-in-directories.hrx"))
+		So(err, ShouldBeNil)
+		So(fsys, ShouldNotBeNil)
+		So(fstest.TestFS(fsys, "dir/file1", "path/to/file2"), ShouldBeNil)
+
+		data, err := fsys.ReadFile("path/to/file2")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "This file is in a deeper directory.\n")
+
+		info, err := fsys.Stat("path/to")
+		So(err, ShouldBeNil)
+		So(info.IsDir(), ShouldBeTrue)
+		So(info.Name(), ShouldEqual, "to")
+		So(info.ModTime().IsZero(), ShouldBeFalse)
+
+		entries, err := fsys.ReadDir(".")
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 2)
+		So(entries[0].Name(), ShouldEqual, "dir")
+		So(entries[1].Name(), ShouldEqual, "path")
+
+		_, err = fsys.ReadDir("dir/file1")
+		So(err, ShouldWrap, hrxutil.ErrNotDirectory)
+		_, err = fsys.ReadFile("dir")
+		So(err, ShouldWrap, fs.ErrInvalid)
+		_, err = fsys.Open("nope")
+		So(err, ShouldWrap, fs.ErrNotExist)
+		_, err = fsys.Open("../dir")
+		So(err, ShouldWrap, fs.ErrInvalid)
+
+		matches, err := fsys.Glob("*/file*")
+		So(err, ShouldBeNil)
+		So(matches, ShouldEqual, []string{"dir/file1"})
+		matches, err = fs.Glob(fsys, "path/*")
+		So(err, ShouldBeNil)
+		So(matches, ShouldEqual, []string{"path/to"})
+		_, err = fsys.Glob("[")
+		So(err, ShouldEqual, path.ErrBadPattern)
+
+	})
+
+	Convey("New", t, func() {
+
+		a := hrx.New("test.hrx", "")
+		So(a.Set("docs/", "", "the documentation"), ShouldBeNil)
+		So(a.Set("docs/readme.txt", "read me\n", "first file"), ShouldBeNil)
+		So(a.Set("empty/", "", ""), ShouldBeNil)
+		So(a.Set("image.bin", "AAEC\n", hrxutil.EncodingBase64), ShouldBeNil)
+
+		fsys, err := New(a)
+		So(err, ShouldBeNil)
+		So(fstest.TestFS(fsys, "docs/readme.txt", "empty", "image.bin"), ShouldBeNil)
+
+		info, err := fsys.Stat("docs")
+		So(err, ShouldBeNil)
+		So(info.Sys(), ShouldEqual, "the documentation")
+		info, err = fsys.Stat("docs/readme.txt")
+		So(err, ShouldBeNil)
+		So(info.Sys(), ShouldEqual, "first file")
+		So(info.Size(), ShouldEqual, 8)
+		So(info.Mode(), ShouldEqual, hrx.DefaultFileMode)
+
+		data, err := fs.ReadFile(fsys, "image.bin")
+		So(err, ShouldBeNil)
+		So(data, ShouldEqual, []byte{0, 1, 2})
+
+		entries, err := fs.ReadDir(fsys, "empty")
+		So(err, ShouldBeNil)
+		So(entries, ShouldBeEmpty)
+
+		So(a.Set("broken.bin", "!!!\n", hrxutil.EncodingBase64), ShouldBeNil)
+		fsys, err = New(a)
+		So(err, ShouldWrap, hrxutil.ErrInvalidEncoding)
+		So(fsys, ShouldBeNil)
+
+	})
+
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hrxtest provides helpers for using hrx archives as test fixtures
+// and golden files
+//
+// Golden archives are rewritten, instead of compared, when the tests are run
+// with the HRXTEST_UPDATE environment variable set:
+//
+//	HRXTEST_UPDATE=1 go test ./...
+//
+// hrxtest does not define any flags of its own, test packages that define an
+// -update flag can use that instead:
+//
+//	var update = flag.Bool("update", false, "rewrite the golden files")
+//
+//	go test ./... -update
+package hrxtest
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+
+	hrxutil "github.com/go-coreutils/hrx"
+	"github.com/go-coreutils/hrx/hrxfs"
+)
+
+const (
+	gUpdateFlag = "update"
+	gUpdateEnv  = "HRXTEST_UPDATE"
+)
+
+// Updating reports whether the golden archives are to be rewritten, which is
+// when the test package defines an -update flag and it is set or, without an
+// -update flag, when HRXTEST_UPDATE is set to a true value
+func Updating() (update bool) {
+	return updating(flag.CommandLine)
+}
+
+func updating(flags *flag.FlagSet) (update bool) {
+	if f := flags.Lookup(gUpdateFlag); f != nil {
+		update, _ = strconv.ParseBool(f.Value.String())
+	} else {
+		update, _ = strconv.ParseBool(os.Getenv(gUpdateEnv))
+	}
+	return
+}
+
+// Extract extracts the `src` archive into a new t.TempDir and returns the
+// path to it, failing the test if the archive cannot be extracted
+func Extract(t testing.TB, src string) (dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	if err := hrxutil.Extract(nil, src, dir); err != nil {
+		t.Fatalf("hrxtest: error extracting %q: %v", src, err)
+	}
+	return
+}
+
+// AssertDirMatches compares every file within the `dir` with the `golden`
+// archive, reporting each missing, unexpected or different file with
+// t.Errorf. When Updating, the `golden` archive is rewritten with the current
+// contents of `dir` instead
+func AssertDirMatches(t testing.TB, dir, golden string) {
+	t.Helper()
+
+	if Updating() {
+		if err := update(dir, golden); err != nil {
+			t.Fatalf("hrxtest: error updating %q: %v", golden, err)
+		}
+		return
+	}
+
+	expected, err := hrxfs.Open(golden)
+	if err != nil {
+		t.Fatalf("hrxtest: error opening %q (update the golden archives to create it): %v", golden, err)
+		return
+	}
+	var want, have map[string][]byte
+	if want, err = readFiles(expected); err != nil {
+		t.Fatalf("hrxtest: error reading %q: %v", golden, err)
+		return
+	} else if have, err = readFiles(os.DirFS(dir)); err != nil {
+		t.Fatalf("hrxtest: error reading %q: %v", dir, err)
+		return
+	}
+
+	for _, name := range sortedNames(want, have) {
+		wanted, present := want[name]
+		data, found := have[name]
+		switch {
+		case !found:
+			t.Errorf("hrxtest: %s: missing from %q", name, dir)
+		case !present:
+			t.Errorf("hrxtest: %s: not in %q", name, golden)
+		case string(data) != string(wanted):
+			t.Errorf("hrxtest: %s: differs from %q\n%s", name, golden, diff(golden, dir, name, wanted, data))
+		}
+	}
+}
+
+// update rewrites the `golden` archive with the files within `dir`, binary
+// files and files with lines that look like a boundary are base64 encoded
+func update(dir, golden string) (err error) {
+	opt := &hrxutil.Options{
+		Recurse:  true,
+		All:      true,
+		NoIgnore: true,
+		Binary:   hrxutil.BinaryBase64,
+	}
+	_, _, err = hrxutil.CreateFromFS(opt, os.DirFS(dir), golden, ".")
+	return
+}
+
+// readFiles returns the contents of every file within the `fsys`
+func readFiles(fsys fs.FS) (files map[string][]byte, err error) {
+	files = make(map[string][]byte)
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		files[name], err = fs.ReadFile(fsys, name)
+		return err
+	})
+	return
+}
+
+// sortedNames returns all the names present in either of the maps, sorted
+func sortedNames(a, b map[string][]byte) (names []string) {
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, present := a[name]; !present {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// diff returns the unified diff between the golden and actual contents of
+// the `name` file, or a short description when either is not text
+func diff(golden, dir, name string, wanted, data []byte) (text string) {
+	if !utf8.Valid(wanted) || !utf8.Valid(data) {
+		return fmt.Sprintf("binary contents differ (%d bytes expected, %d bytes found)", len(wanted), len(data))
+	}
+	edits := myers.ComputeEdits(span.URIFromPath(name), string(wanted), string(data))
+	return fmt.Sprint(gotextdiff.ToUnified(golden+":"+name, filepath.Join(dir, name), string(wanted), edits))
+}
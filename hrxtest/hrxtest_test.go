@@ -0,0 +1,139 @@
+// Copyright (c) 2024  The Go-CoreUtils Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hrxtest
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// gUpdate is defined the same way a test package using hrxtest would
+var gUpdate = flag.Bool(gUpdateFlag, false, "rewrite the golden files")
+
+// recorder is a testing.TB that records failures instead of failing
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, argv ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, argv...))
+}
+
+func (r *recorder) Fatalf(format string, argv ...interface{}) {
+	r.fatal = fmt.Sprintf(format, argv...)
+}
+
+func Test(t *testing.T) {
+
+	testdata, _ := filepath.Abs("../testdata")
+
+	Convey("Extract", t, func() {
+
+		dir := Extract(t, filepath.Join(testdata, "files-in-directories.hrx"))
+		data, err := os.ReadFile(filepath.Join(dir, "path", "to", "file2"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "This file is in a deeper directory.\n")
+
+		r := &recorder{TB: t}
+		_ = Extract(r, filepath.Join(testdata, "nope.hrx"))
+		So(r.fatal, ShouldContainSubstring, "nope.hrx")
+
+	})
+
+	Convey("AssertDirMatches", t, func() {
+
+		golden := filepath.Join(testdata, "files-in-directories.hrx")
+		dir := Extract(t, golden)
+
+		r := &recorder{TB: t}
+		AssertDirMatches(r, dir, golden)
+		So(r.errors, ShouldBeEmpty)
+		So(r.fatal, ShouldEqual, "")
+
+		So(os.WriteFile(filepath.Join(dir, "dir", "file1"), []byte("changed\n"), 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "extra.txt"), []byte("extra\n"), 0660), ShouldBeNil)
+		So(os.Remove(filepath.Join(dir, "path", "to", "file2")), ShouldBeNil)
+		AssertDirMatches(r, dir, golden)
+		So(r.errors, ShouldHaveLength, 3)
+		So(r.errors[0], ShouldStartWith, "hrxtest: dir/file1: differs from")
+		So(r.errors[0], ShouldContainSubstring, "\n+changed\n")
+		So(r.errors[1], ShouldStartWith, "hrxtest: extra.txt: not in")
+		So(r.errors[2], ShouldStartWith, "hrxtest: path/to/file2: missing from")
+
+		r = &recorder{TB: t}
+		AssertDirMatches(r, dir, filepath.Join(t.TempDir(), "missing.hrx"))
+		So(r.fatal, ShouldContainSubstring, "update the golden archives to create it")
+
+	})
+
+	Convey("Update", t, func() {
+
+		So(Updating(), ShouldBeFalse)
+		So(flag.Set(gUpdateFlag, "true"), ShouldBeNil)
+		defer func() { _ = flag.Set(gUpdateFlag, "false") }()
+		So(*gUpdate, ShouldBeTrue)
+		So(Updating(), ShouldBeTrue)
+
+		// the environment is only used without an -update flag
+		t.Setenv(gUpdateEnv, "1")
+		So(updating(flag.NewFlagSet("none", flag.ContinueOnError)), ShouldBeTrue)
+		So(flag.Set(gUpdateFlag, "false"), ShouldBeNil)
+		So(Updating(), ShouldBeFalse)
+		t.Setenv(gUpdateEnv, "")
+		So(updating(flag.NewFlagSet("none", flag.ContinueOnError)), ShouldBeFalse)
+		So(flag.Set(gUpdateFlag, "true"), ShouldBeNil)
+
+		dir := Extract(t, filepath.Join(testdata, "files-in-directories.hrx"))
+		So(os.WriteFile(filepath.Join(dir, "dir", "file1"), []byte("changed\n"), 0660), ShouldBeNil)
+		golden := filepath.Join(t.TempDir(), "golden.hrx")
+
+		r := &recorder{TB: t}
+		AssertDirMatches(r, dir, golden)
+		So(r.fatal, ShouldEqual, "")
+		data, err := os.ReadFile(golden)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "<=====> dir/file1\nchanged\n\n<=====> path/to/file2\nThis file is in a deeper directory.\n")
+
+		So(flag.Set(gUpdateFlag, "false"), ShouldBeNil)
+		AssertDirMatches(r, dir, golden)
+		So(r.errors, ShouldBeEmpty)
+		So(r.fatal, ShouldEqual, "")
+
+		// files with lines that look like a boundary still read back the same
+		So(flag.Set(gUpdateFlag, "true"), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "boundary.txt"), []byte("<===> looks like boundary\n<=====> same size\n"), 0660), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "nested.hrx"), []byte("<=====> inner.txt\ninner\n"), 0660), ShouldBeNil)
+		AssertDirMatches(r, dir, golden)
+		So(r.fatal, ShouldEqual, "")
+		So(flag.Set(gUpdateFlag, "false"), ShouldBeNil)
+		AssertDirMatches(r, dir, golden)
+		So(r.errors, ShouldBeEmpty)
+		So(r.fatal, ShouldEqual, "")
+		data, err = os.ReadFile(golden)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldContainSubstring, "<======>\nencoding: base64\n<======> boundary.txt\n")
+
+	})
+
+}